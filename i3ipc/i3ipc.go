@@ -0,0 +1,211 @@
+// Package i3ipc is a small client for the i3 and sway IPC protocol, which
+// exchanges JSON messages over a unix socket.
+package i3ipc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// magic starts every message, in both directions.
+const magic = "i3-ipc"
+
+// Message types.
+const (
+	RunCommand      = 0
+	Subscribe       = 2
+	GetTree         = 4
+	GetBindingState = 12
+	GetInputs       = 100 // sway only
+)
+
+// eventBit is set in the type of messages that are events rather than
+// replies.
+const eventBit = 1 << 31
+
+// Event types, as received.
+const (
+	WorkspaceEvent = eventBit | 0
+	ModeEvent      = eventBit | 2
+	WindowEvent    = eventBit | 3
+	InputEvent     = eventBit | 21 // sway only
+)
+
+// Event represents an event received from a subscription.
+type Event struct {
+	// Type is the event type, e.g. InputEvent.
+	Type uint32
+	// Payload is the JSON body of the event.
+	Payload json.RawMessage
+}
+
+// Conn represents a connection to the window manager.
+type Conn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// SocketPath returns the IPC socket of the running window manager, from
+// $I3SOCK or $SWAYSOCK, or by asking i3. Sway sets both to the same socket,
+// so $I3SOCK is checked first, which lets tests point it at a fake server.
+func SocketPath() (string, error) {
+	for _, env := range []string{"I3SOCK", "SWAYSOCK"} {
+		if path := os.Getenv(env); path != "" {
+			return path, nil
+		}
+	}
+	out, err := exec.Command("i3", "--get-socketpath").Output()
+	if err != nil {
+		return "", fmt.Errorf("no i3 or sway IPC socket: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsSway returns true if running under sway rather than i3.
+func IsSway() bool {
+	return os.Getenv("SWAYSOCK") != ""
+}
+
+// Dial connects to the IPC socket at the given path, e.g. a fake server
+// on a temporary socket.
+func Dial(path string) (*Conn, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn}, nil
+}
+
+// Connect connects to the running window manager. Pointing $I3SOCK at a
+// fake server is enough to use it instead.
+func Connect() (*Conn, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	return Dial(path)
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Request sends a message and decodes the reply into reply, if not nil.
+func (c *Conn) Request(typ uint32, payload string, reply interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := write(c.conn, typ, payload); err != nil {
+		return err
+	}
+	t, body, err := read(c.conn)
+	if err != nil {
+		return err
+	}
+	if t != typ {
+		return fmt.Errorf("i3ipc: got reply type %d, want %d", t, typ)
+	}
+	if reply == nil {
+		return nil
+	}
+	return json.Unmarshal(body, reply)
+}
+
+// Command runs window manager commands, e.g. "workspace 3".
+func (c *Conn) Command(cmd string) error {
+	var results []struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := c.Request(RunCommand, cmd, &results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			return errors.New(r.Error)
+		}
+	}
+	return nil
+}
+
+// BindingMode returns the name of the current binding mode, e.g. "default".
+func (c *Conn) BindingMode() (string, error) {
+	var reply struct {
+		Name string `json:"name"`
+	}
+	err := c.Request(GetBindingState, "", &reply)
+	return reply.Name, err
+}
+
+// Subscribe subscribes to the named events, e.g. "input", and returns a
+// channel of events. The connection is dedicated to events afterwards, and
+// the channel is closed when the connection fails or is closed.
+func (c *Conn) Subscribe(events ...string) (<-chan Event, error) {
+	names, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	if err := c.Request(Subscribe, string(names), &reply); err != nil {
+		return nil, err
+	}
+	if !reply.Success {
+		return nil, fmt.Errorf("i3ipc: could not subscribe to %v", events)
+	}
+	ch := make(chan Event, 10)
+	go func() {
+		defer close(ch)
+		for {
+			t, body, err := read(c.conn)
+			if err != nil {
+				return
+			}
+			if t&eventBit != 0 {
+				ch <- Event{Type: t, Payload: body}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Messages are the magic string, the payload length and message type as
+// 32-bit integers in native byte order (little endian on every platform
+// i3 and sway run on), then the payload.
+const headerLen = len(magic) + 8
+
+func write(w io.Writer, typ uint32, payload string) error {
+	buf := make([]byte, headerLen+len(payload))
+	copy(buf, magic)
+	binary.LittleEndian.PutUint32(buf[len(magic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[len(magic)+4:], typ)
+	copy(buf[headerLen:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+func read(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, errors.New("i3ipc: bad magic")
+	}
+	size := binary.LittleEndian.Uint32(header[len(magic):])
+	typ := binary.LittleEndian.Uint32(header[len(magic)+4:])
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
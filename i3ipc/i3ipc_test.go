@@ -0,0 +1,121 @@
+package i3ipc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aolwas/mybarista/i3ipc/i3ipctest"
+)
+
+func TestEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	if err := write(&buf, GetTree, "abc"); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte("i3-ipc\x03\x00\x00\x00\x04\x00\x00\x00abc")
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("encoded %q, want %q", buf.Bytes(), want)
+	}
+	i3ipctest.Write(&buf, ModeEvent, `{"change":"resize"}`)
+	if typ, body, err := read(&buf); err != nil || typ != GetTree || string(body) != "abc" {
+		t.Errorf("decoded %d %q %v, want %d abc", typ, body, err, GetTree)
+	}
+	if typ, body, err := read(&buf); err != nil || typ != ModeEvent || string(body) != `{"change":"resize"}` {
+		t.Errorf("decoded %d %q %v, want a mode event", typ, body, err)
+	}
+	if _, _, err := read(bytes.NewBufferString("i3-bad\x00\x00\x00\x00\x00\x00\x00\x00")); err == nil {
+		t.Error("no error for bad magic")
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	t.Setenv("SWAYSOCK", "/run/sway.sock")
+	t.Setenv("I3SOCK", "/run/i3.sock")
+	if path, _ := SocketPath(); path != "/run/i3.sock" {
+		t.Errorf("SocketPath() = %q, want $I3SOCK", path)
+	}
+	t.Setenv("I3SOCK", "")
+	if path, _ := SocketPath(); path != "/run/sway.sock" {
+		t.Errorf("SocketPath() = %q, want $SWAYSOCK", path)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	srv := i3ipctest.New(t)
+	conn, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	srv.Reply(RunCommand, `[{"success":true}]`)
+	if err := conn.Command("workspace 3"); err != nil {
+		t.Errorf("Command: %v", err)
+	}
+	srv.Reply(RunCommand, `[{"success":false,"error":"no such workspace"}]`)
+	if err := conn.Command("workspace nope"); err == nil || err.Error() != "no such workspace" {
+		t.Errorf("Command error = %v, want no such workspace", err)
+	}
+	want := []i3ipctest.Request{
+		{Type: RunCommand, Payload: "workspace 3"},
+		{Type: RunCommand, Payload: "workspace nope"},
+	}
+	if got := srv.Requests(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestBindingMode(t *testing.T) {
+	srv := i3ipctest.New(t)
+	srv.Reply(GetBindingState, `{"name":"resize"}`)
+	conn, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if mode, err := conn.BindingMode(); err != nil || mode != "resize" {
+		t.Errorf("BindingMode() = %q, %v, want resize", mode, err)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	srv := i3ipctest.New(t)
+	conn, err := Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := conn.Subscribe("mode", "window")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := <-srv.Subscribed(); len(names) != 2 || names[0] != "mode" || names[1] != "window" {
+		t.Errorf("subscribed to %v, want [mode window]", names)
+	}
+
+	srv.Event(ModeEvent, `{"change":"resize"}`)
+	srv.Event(WindowEvent, `{"change":"focus"}`)
+	for _, want := range []uint32{ModeEvent, WindowEvent} {
+		select {
+		case e := <-events:
+			var payload struct{ Change string }
+			json.Unmarshal(e.Payload, &payload)
+			if e.Type != want || payload.Change == "" {
+				t.Errorf("event %d %s, want type %d", e.Type, e.Payload, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+	}
+
+	conn.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("unexpected event after close")
+		}
+	case <-time.After(time.Second):
+		t.Error("events not closed with the connection")
+	}
+}
@@ -0,0 +1,145 @@
+// Package i3ipctest runs a fake i3 IPC server on a temporary socket, for
+// testing code that talks to i3 or sway.
+package i3ipctest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const magic = "i3-ipc"
+
+// subscribe is the message type of subscriptions.
+const subscribe = 2
+
+// Request is a message received by the server.
+type Request struct {
+	Type    uint32
+	Payload string
+}
+
+// Server is a fake i3 IPC server.
+type Server struct {
+	// Path is the socket path, also set as $I3SOCK.
+	Path string
+
+	mu          sync.Mutex
+	replies     map[uint32]string
+	requests    []Request
+	subscribers []net.Conn
+	subscribed  chan []string
+}
+
+// New starts a fake server on a temporary socket, and points $I3SOCK at
+// it for the duration of the test.
+func New(t *testing.T) *Server {
+	t.Helper()
+	s := &Server{
+		Path:       filepath.Join(t.TempDir(), "ipc.sock"),
+		replies:    map[uint32]string{},
+		subscribed: make(chan []string, 10),
+	}
+	l, err := net.Listen("unix", s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("I3SOCK", s.Path)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Reply configures the reply to messages of the given type.
+func (s *Server) Reply(typ uint32, payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[typ] = payload
+}
+
+// Requests returns the messages received so far, except subscriptions.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Subscribed returns a channel that receives the event names of each
+// subscription, so that tests can wait for a client to subscribe.
+func (s *Server) Subscribed() <-chan []string {
+	return s.subscribed
+}
+
+// Event sends an event to all subscribed clients. Event types have the
+// high bit set, e.g. i3ipc.ModeEvent.
+func (s *Server) Event(typ uint32, payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.subscribers {
+		Write(conn, typ, payload)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	for {
+		typ, payload, err := Read(conn)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if typ == subscribe {
+			var events []string
+			json.Unmarshal([]byte(payload), &events)
+			Write(conn, typ, `{"success":true}`)
+			s.subscribers = append(s.subscribers, conn)
+			s.mu.Unlock()
+			s.subscribed <- events
+			continue
+		}
+		s.requests = append(s.requests, Request{typ, payload})
+		reply, ok := s.replies[typ]
+		if !ok {
+			reply = "null"
+		}
+		Write(conn, typ, reply)
+		s.mu.Unlock()
+	}
+}
+
+// Write writes a message: the magic string, then the payload length and
+// message type as little endian 32-bit integers, then the payload.
+func Write(w io.Writer, typ uint32, payload string) error {
+	buf := make([]byte, len(magic)+8+len(payload))
+	copy(buf, magic)
+	binary.LittleEndian.PutUint32(buf[6:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[10:], typ)
+	copy(buf[14:], payload)
+	_, err := w.Write(buf)
+	return err
+}
+
+// Read reads a message.
+func Read(r io.Reader) (uint32, string, error) {
+	header := make([]byte, len(magic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, "", err
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header[6:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, "", err
+	}
+	return binary.LittleEndian.Uint32(header[10:]), string(body), nil
+}
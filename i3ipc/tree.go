@@ -0,0 +1,112 @@
+package i3ipc
+
+import (
+	"errors"
+)
+
+// ScratchpadWorkspace is the name of the hidden workspace holding the
+// windows moved to the scratchpad.
+const ScratchpadWorkspace = "__i3_scratch"
+
+// Node represents a node of the layout tree: the root, an output, a
+// workspace, a split container or a window.
+type Node struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Focused bool   `json:"focused"`
+	Urgent  bool   `json:"urgent"`
+	// Nodes and FloatingNodes are the tiling and floating children.
+	Nodes         []*Node `json:"nodes"`
+	FloatingNodes []*Node `json:"floating_nodes"`
+}
+
+// Tree returns the layout tree.
+func (c *Conn) Tree() (*Node, error) {
+	var root Node
+	if err := c.Request(GetTree, "", &root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// Find returns the first node, depth first, for which match returns true,
+// or nil if there is none.
+func (n *Node) Find(match func(*Node) bool) *Node {
+	if match(n) {
+		return n
+	}
+	for _, children := range [][]*Node{n.Nodes, n.FloatingNodes} {
+		for _, child := range children {
+			if found := child.Find(match); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// Workspaces returns all workspaces, including the scratchpad.
+func (n *Node) Workspaces() []*Node {
+	if n.Type == "workspace" {
+		return []*Node{n}
+	}
+	var all []*Node
+	for _, child := range n.Nodes {
+		all = append(all, child.Workspaces()...)
+	}
+	return all
+}
+
+// WatchTree returns a channel that receives the layout tree once, then again
+// after each of the named events, e.g. "window". The channel is closed when
+// a connection fails, after sending an error on errs.
+func WatchTree(events ...string) (trees <-chan *Node, errs <-chan error) {
+	treeCh := make(chan *Node, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(treeCh)
+		query, err := Connect()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer query.Close()
+		conn, err := Connect()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		ch, err := conn.Subscribe(events...)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		for {
+			root, err := query.Tree()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			treeCh <- root
+			// Several events often arrive together, read the tree once.
+			if _, ok := <-ch; !ok {
+				errCh <- errors.New("lost i3 IPC connection")
+				return
+			}
+			for drained := false; !drained; {
+				select {
+				case _, ok := <-ch:
+					if !ok {
+						errCh <- errors.New("lost i3 IPC connection")
+						return
+					}
+				default:
+					drained = true
+				}
+			}
+		}
+	}()
+	return treeCh, errCh
+}
@@ -0,0 +1,182 @@
+// Package logind is a small client for systemd-logind's D-Bus API. It works
+// on any bus connection, so it can be pointed at a stub logind on a private
+// bus instead of the system bus.
+package logind
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	dest         = "org.freedesktop.login1"
+	path         = dbus.ObjectPath("/org/freedesktop/login1")
+	managerIface = dest + ".Manager"
+	sessionIface = dest + ".Session"
+)
+
+// Manager represents logind's manager object.
+type Manager struct {
+	conn *dbus.Conn
+}
+
+// New constructs a logind manager on the given bus connection.
+func New(conn *dbus.Conn) *Manager {
+	return &Manager{conn: conn}
+}
+
+// System constructs a logind manager on the system bus.
+func System() (*Manager, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, err
+	}
+	return New(conn), nil
+}
+
+func (m *Manager) call(method string, args ...interface{}) *dbus.Call {
+	return m.conn.Object(dest, path).Call(managerIface+"."+method, 0, args...)
+}
+
+// Suspend suspends the system to RAM.
+func (m *Manager) Suspend() error {
+	// Not interactive: polkit must not prompt from a status bar.
+	return m.call("Suspend", false).Err
+}
+
+// Hibernate suspends the system to disk.
+func (m *Manager) Hibernate() error {
+	return m.call("Hibernate", false).Err
+}
+
+// Reboot reboots the system.
+func (m *Manager) Reboot() error {
+	return m.call("Reboot", false).Err
+}
+
+// PowerOff shuts the system down.
+func (m *Manager) PowerOff() error {
+	return m.call("PowerOff", false).Err
+}
+
+// Inhibit takes an inhibitor lock, e.g. for "idle:sleep". The lock is held
+// until the returned file is closed. The file is close-on-exec, so that
+// commands started by the bar do not keep the lock alive after it exits.
+func (m *Manager) Inhibit(what, who, why, mode string) (*os.File, error) {
+	// The descriptor is received without close-on-exec. Holding the fork
+	// lock until it is set keeps commands started concurrently (os/exec
+	// takes the lock to fork) from inheriting it.
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	var fd dbus.UnixFD
+	if err := m.call("Inhibit", what, who, why, mode).Store(&fd); err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(int(fd))
+	return os.NewFile(uintptr(fd), "inhibit:"+what), nil
+}
+
+// Session represents a logind session.
+type Session struct {
+	conn *dbus.Conn
+	path dbus.ObjectPath
+}
+
+// Session returns the session of the calling process.
+func (m *Manager) Session() *Session {
+	return &Session{conn: m.conn, path: path + "/session/auto"}
+}
+
+// SetBrightness sets the brightness of a backlight or LED device owned by
+// the session's seat, without requiring root.
+func (s *Session) SetBrightness(subsystem, name string, brightness uint32) error {
+	return s.conn.Object(dest, s.path).
+		Call(sessionIface+".SetBrightness", 0, subsystem, name, brightness).Err
+}
+
+// Lock asks the session's screen locker to lock the screen.
+func (s *Session) Lock() error {
+	return s.conn.Object(dest, s.path).Call(sessionIface+".Lock", 0).Err
+}
+
+// Terminate ends the session, logging the user out.
+func (s *Session) Terminate() error {
+	return s.conn.Object(dest, s.path).Call(sessionIface+".Terminate", 0).Err
+}
+
+// resolve returns the real object path of the session. Signals are emitted
+// on that path rather than on the "auto" alias.
+func (s *Session) resolve() (dbus.ObjectPath, error) {
+	id, err := s.conn.Object(dest, s.path).GetProperty(sessionIface + ".Id")
+	if err != nil {
+		return "", err
+	}
+	var p dbus.ObjectPath
+	err = s.conn.Object(dest, path).
+		Call(managerIface+".GetSession", 0, id.Value()).Store(&p)
+	return p, err
+}
+
+// Locked returns whether the screen locker reports the session as locked.
+func (s *Session) Locked() (bool, error) {
+	v, err := s.conn.Object(dest, s.path).GetProperty(sessionIface + ".LockedHint")
+	if err != nil {
+		return false, err
+	}
+	locked, _ := v.Value().(bool)
+	return locked, nil
+}
+
+// WatchLock returns a channel that receives true when the session is locked
+// and false when it is unlocked, starting with the current state. Both the
+// Lock/Unlock requests and the LockedHint property are watched, since not
+// every screen locker sets the hint.
+func (s *Session) WatchLock() (<-chan bool, error) {
+	p, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+	err = s.conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0,
+		"type='signal',sender='"+dest+"',path='"+string(p)+"'").Err
+	if err != nil {
+		return nil, err
+	}
+	locked, err := s.Locked()
+	if err != nil {
+		return nil, err
+	}
+	signals := make(chan *dbus.Signal, 10)
+	s.conn.Signal(signals)
+	ch := make(chan bool, 1)
+	ch <- locked
+	go func() {
+		for sig := range signals {
+			if sig.Path != p {
+				continue
+			}
+			l := locked
+			switch sig.Name {
+			case sessionIface + ".Lock":
+				l = true
+			case sessionIface + ".Unlock":
+				l = false
+			case "org.freedesktop.DBus.Properties.PropertiesChanged":
+				if len(sig.Body) < 2 {
+					continue
+				}
+				changed, _ := sig.Body[1].(map[string]dbus.Variant)
+				if v, ok := changed["LockedHint"]; ok {
+					l, _ = v.Value().(bool)
+				}
+			}
+			if l != locked {
+				locked = l
+				ch <- locked
+			}
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
@@ -0,0 +1,31 @@
+package logind_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aolwas/mybarista/logind"
+	"github.com/aolwas/mybarista/logind/logindtest"
+)
+
+func TestManagerCalls(t *testing.T) {
+	for _, tc := range []struct {
+		call func(*logind.Manager) error
+		want string
+	}{
+		{(*logind.Manager).Suspend, "Manager.Suspend"},
+		{(*logind.Manager).Hibernate, "Manager.Hibernate"},
+		{(*logind.Manager).Reboot, "Manager.Reboot"},
+		{(*logind.Manager).PowerOff, "Manager.PowerOff"},
+		{func(m *logind.Manager) error { return m.Session().Lock() }, "Session.Lock"},
+		{func(m *logind.Manager) error { return m.Session().Terminate() }, "Session.Terminate"},
+	} {
+		m, stub := logindtest.New(t)
+		if err := tc.call(m); err != nil {
+			t.Errorf("%s: %v", tc.want, err)
+		}
+		if got := stub.Calls(); !reflect.DeepEqual(got, []string{tc.want}) {
+			t.Errorf("calls = %v, want [%s]", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package logindtest runs a stub logind on a private bus, so that code
+// calling logind can be tested without suspending the machine.
+package logindtest
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus"
+
+	"github.com/aolwas/mybarista/logind"
+)
+
+// Stub records the calls made to it.
+type Stub struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+// Calls returns the methods called so far, e.g. "Manager.Hibernate".
+func (s *Stub) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *Stub) record(call string) *dbus.Error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+	return nil
+}
+
+type manager struct{ *Stub }
+
+func (m manager) Suspend(interactive bool) *dbus.Error   { return m.record("Manager.Suspend") }
+func (m manager) Hibernate(interactive bool) *dbus.Error { return m.record("Manager.Hibernate") }
+func (m manager) Reboot(interactive bool) *dbus.Error    { return m.record("Manager.Reboot") }
+func (m manager) PowerOff(interactive bool) *dbus.Error  { return m.record("Manager.PowerOff") }
+
+type session struct{ *Stub }
+
+func (s session) Lock() *dbus.Error      { return s.record("Session.Lock") }
+func (s session) Terminate() *dbus.Error { return s.record("Session.Terminate") }
+
+// New starts a private bus with a stub logind on it, and returns a manager
+// connected to it. The test is skipped if dbus-daemon is not installed.
+func New(t *testing.T) (*logind.Manager, *Stub) {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address")
+	out, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = strings.TrimSpace(addr)
+
+	stub := &Stub{}
+	service := connect(t, addr)
+	service.Export(manager{stub}, "/org/freedesktop/login1", "org.freedesktop.login1.Manager")
+	service.Export(session{stub}, "/org/freedesktop/login1/session/auto", "org.freedesktop.login1.Session")
+	reply, err := service.RequestName("org.freedesktop.login1", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own org.freedesktop.login1: %v", err)
+	}
+	return logind.New(connect(t, addr)), stub
+}
+
+func connect(t *testing.T, addr string) *dbus.Conn {
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
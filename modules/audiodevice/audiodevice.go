@@ -0,0 +1,97 @@
+// Package audiodevice provides an i3bar module that shows the default
+// PulseAudio/PipeWire sink and source, and switches between devices on click.
+package audiodevice
+
+import (
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/pulse"
+)
+
+// Info represents the current default devices.
+type Info struct {
+	// Sink and Source are the friendly names of the default devices.
+	Sink, Source string
+}
+
+// Module represents an audio device switcher bar module.
+type Module struct {
+	sinkButton   bar.Button
+	sourceButton bar.Button
+	info         base.ErrorValue // of Info
+	outputFunc   base.Value      // of func(Info) bar.Output
+}
+
+// New constructs an audio device switcher. By default, a left click cycles
+// through sinks and a right click cycles through sources.
+func New() *Module {
+	m := &Module{sinkButton: bar.ButtonLeft, sourceButton: bar.ButtonRight}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text(i.Sink)
+	})
+	return m
+}
+
+// Buttons configures the buttons used to cycle sinks and sources.
+func (m *Module) Buttons(sink, source bar.Button) *Module {
+	m.sinkButton, m.sourceButton = sink, source
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Click switches the default sink or source to the next available device,
+// moving existing streams along with it.
+func (m *Module) Click(e bar.Event) {
+	switch e.Button {
+	case m.sinkButton:
+		m.info.Error(pulse.CycleDefaultSink())
+	case m.sourceButton:
+		m.info.Error(pulse.CycleDefaultSource())
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	changes := pulse.Changes()
+	m.refresh()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-changes:
+			changes = pulse.Changes()
+			m.refresh()
+			continue
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) refresh() {
+	sink, err := pulse.DefaultSink()
+	if m.info.Error(err) {
+		return
+	}
+	// Not every setup has a microphone, which is not an error.
+	source, _ := pulse.DefaultSource()
+	i := Info{Sink: sink.Description, Source: source.Description}
+	if old, err := m.info.Get(); err == nil && old == i {
+		return
+	}
+	m.info.Set(i)
+}
@@ -0,0 +1,206 @@
+// Package backlight provides an i3bar module that shows the screen
+// brightness, updated through inotify and kernel uevents, and changes it on
+// scroll through logind so that no root helper is needed.
+package backlight
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/logind"
+	"github.com/aolwas/mybarista/uevent"
+)
+
+// backlightDir is where the kernel exposes backlight devices.
+var backlightDir = "/sys/class/backlight"
+
+// Info represents the brightness of a backlight device.
+type Info struct {
+	Brightness, Max int
+}
+
+// Percent returns the brightness as a percentage of the maximum.
+func (i Info) Percent() int {
+	if i.Max <= 0 {
+		return 0
+	}
+	return int(float64(i.Brightness)/float64(i.Max)*100 + 0.5)
+}
+
+// Module represents a backlight bar module.
+type Module struct {
+	device     string
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// Device constructs a backlight module for the named device,
+// e.g. "intel_backlight".
+func Device(name string) *Module {
+	m := &Module{device: name}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("BRI %d%%", i.Percent())
+	})
+	return m
+}
+
+// Default constructs a backlight module for the first backlight device.
+func Default() *Module {
+	devices, _ := filepath.Glob(filepath.Join(backlightDir, "*"))
+	if len(devices) == 0 {
+		return Device("")
+	}
+	return Device(filepath.Base(devices[0]))
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Click changes the brightness on scroll.
+func (m *Module) Click(e bar.Event) {
+	i, err := m.read()
+	if m.info.Error(err) {
+		return
+	}
+	var target int
+	switch e.Button {
+	case bar.ScrollUp, bar.ScrollRight:
+		target = stepUp(i)
+	case bar.ScrollDown, bar.ScrollLeft:
+		target = stepDown(i)
+	default:
+		return
+	}
+	lgd, err := logind.System()
+	if m.info.Error(err) {
+		return
+	}
+	// The new value is picked up through inotify.
+	m.info.Error(lgd.Session().SetBrightness("backlight", m.device, uint32(target)))
+}
+
+// Steps are multiplicative at the low end, where the eye is most sensitive,
+// and capped to a linear step of maxStepPct at the high end.
+const (
+	stepRatio  = 1.25
+	maxStepPct = 5
+)
+
+func stepUp(i Info) int {
+	target := int(float64(i.Brightness) * stepRatio)
+	if linear := i.Brightness + i.Max*maxStepPct/100; target > linear {
+		target = linear
+	}
+	if target <= i.Brightness {
+		target = i.Brightness + 1
+	}
+	if target > i.Max {
+		target = i.Max
+	}
+	return target
+}
+
+func stepDown(i Info) int {
+	target := int(float64(i.Brightness) / stepRatio)
+	if linear := i.Brightness - i.Max*maxStepPct/100; target < linear {
+		target = linear
+	}
+	if target >= i.Brightness {
+		target = i.Brightness - 1
+	}
+	// Never turn the backlight off completely.
+	if target < 1 {
+		target = 1
+	}
+	return target
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	if m.device == "" {
+		s.Error(fmt.Errorf("no backlight device found"))
+		return
+	}
+	// Writes to the brightness file trigger inotify, while changes made by
+	// the firmware (e.g. hotkeys on some laptops) only send a uevent.
+	changes, err := watch(filepath.Join(backlightDir, m.device, "brightness"))
+	events, uerr := uevent.Subscribe("backlight")
+	if err != nil && uerr != nil {
+		s.Error(err)
+		return
+	}
+	m.refresh()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case e, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Base(e.DevPath) == m.device {
+				m.refresh()
+			}
+			continue
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			m.refresh()
+			continue
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) refresh() {
+	i, err := m.read()
+	if m.info.Error(err) {
+		return
+	}
+	if old, err := m.info.Get(); err == nil && old == i {
+		return
+	}
+	m.info.Set(i)
+}
+
+func (m *Module) read() (Info, error) {
+	dir := filepath.Join(backlightDir, m.device)
+	brightness, err := readInt(filepath.Join(dir, "brightness"))
+	if err != nil {
+		return Info{}, err
+	}
+	max, err := readInt(filepath.Join(dir, "max_brightness"))
+	if err != nil {
+		return Info{}, err
+	}
+	return Info{Brightness: brightness, Max: max}, nil
+}
+
+func readInt(file string) (int, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,39 @@
+package backlight
+
+import (
+	"syscall"
+)
+
+// watch returns a channel that receives a value every time the file is
+// modified. The watch stays active for the lifetime of the process.
+func watch(file string) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := syscall.InotifyAddWatch(fd, file, syscall.IN_MODIFY); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer syscall.Close(fd)
+		defer close(ch)
+		buf := make([]byte, 4096)
+		for {
+			_, err := syscall.Read(fd, buf)
+			if err == syscall.EINTR {
+				continue
+			}
+			if err != nil {
+				return
+			}
+			// Coalesce bursts of events, only the latest value matters.
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
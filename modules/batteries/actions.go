@@ -0,0 +1,109 @@
+package batteries
+
+import (
+	"time"
+)
+
+// Action is run once per discharge when the batteries drop below a
+// threshold. Actions are re-armed when external power is connected.
+type Action struct {
+	// Below triggers the action when the remaining time is known and lower
+	// than this duration. The smoothed estimate is used if available.
+	Below time.Duration
+	// BelowPct triggers the action when the combined charge is lower than
+	// this percentage, for batteries that do not report their power draw.
+	BelowPct int
+	// Name is shown on the bar during the grace period, e.g. "hibernate".
+	Name string
+	// Grace delays the action, showing a countdown on the bar. Connecting
+	// external power during the countdown cancels the action.
+	Grace time.Duration
+	// Do performs the action.
+	Do func() error
+}
+
+func (a Action) triggered(i Info) bool {
+	// A misread must never hibernate a healthy machine.
+	if i.PluggedIn() || !i.Known() {
+		return false
+	}
+	remaining := i.SmoothedRemainingTime()
+	if a.Below > 0 && remaining > 0 && remaining < a.Below {
+		return true
+	}
+	return a.BelowPct > 0 && i.RemainingPct() < a.BelowPct
+}
+
+// Pending represents an action waiting for its grace period to run out.
+type Pending struct {
+	// Name of the action.
+	Name string
+	// Remaining time before the action runs.
+	Remaining time.Duration
+}
+
+// On configures actions to run when the batteries run low.
+func (m *Module) On(actions ...Action) *Module {
+	m.actions = actions
+	return m
+}
+
+// actionState tracks which actions have fired during the current discharge,
+// and the deadline of the action in its grace period, if any.
+type actionState struct {
+	fired    []bool
+	pending  int
+	deadline time.Time
+}
+
+func newActionState(count int) *actionState {
+	return &actionState{fired: make([]bool, count), pending: -1}
+}
+
+// updateActions runs or schedules triggered actions, and returns the pending
+// action, if any. Errors from actions are returned so that they can be shown.
+func (m *Module) updateActions(st *actionState, i Info, now time.Time) (*Pending, error) {
+	if i.PluggedIn() {
+		// Re-arm everything, and cancel any countdown.
+		for idx := range st.fired {
+			st.fired[idx] = false
+		}
+		st.pending = -1
+		return nil, nil
+	}
+	if st.pending >= 0 && !m.actions[st.pending].triggered(i) {
+		// The batteries recovered, e.g. after a misread or a spike in
+		// power draw, so the action is no longer needed.
+		st.pending = -1
+	}
+	var err error
+	for idx, a := range m.actions {
+		if st.fired[idx] || st.pending == idx || !a.triggered(i) {
+			continue
+		}
+		if a.Grace > 0 {
+			if st.pending < 0 {
+				st.pending = idx
+				st.deadline = now.Add(a.Grace)
+			}
+			continue
+		}
+		st.fired[idx] = true
+		if e := a.Do(); e != nil {
+			err = e
+		}
+	}
+	if st.pending < 0 {
+		return nil, err
+	}
+	a := m.actions[st.pending]
+	if !now.Before(st.deadline) {
+		st.fired[st.pending] = true
+		st.pending = -1
+		if e := a.Do(); e != nil {
+			err = e
+		}
+		return nil, err
+	}
+	return &Pending{Name: a.Name, Remaining: st.deadline.Sub(now)}, err
+}
@@ -0,0 +1,138 @@
+package batteries
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aolwas/mybarista/logind/logindtest"
+)
+
+func discharging(pct float64) Info {
+	return Info{
+		Batteries:  []Battery{{Name: "BAT0", Status: "Discharging"}},
+		EnergyNow:  pct,
+		EnergyFull: 100,
+	}
+}
+
+func pluggedIn(pct float64) Info {
+	i := discharging(pct)
+	i.ACOnline = true
+	return i
+}
+
+type step struct {
+	at          time.Duration
+	info        Info
+	wantPending string
+	wantCalls   int
+}
+
+func TestUpdateActions(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		grace time.Duration
+		steps []step
+	}{
+		{"fires once per discharge", 0, []step{
+			{0, discharging(50), "", 0},
+			{time.Minute, discharging(8), "", 1},
+			{2 * time.Minute, discharging(5), "", 1},
+			{3 * time.Minute, discharging(3), "", 1},
+		}},
+		{"re-arms on AC", 0, []step{
+			{0, discharging(8), "", 1},
+			{time.Minute, pluggedIn(8), "", 1},
+			{2 * time.Minute, discharging(8), "", 2},
+		}},
+		{"grace countdown", time.Minute, []step{
+			{0, discharging(8), "test", 0},
+			{30 * time.Second, discharging(8), "test", 0},
+			{time.Minute, discharging(8), "", 1},
+			{2 * time.Minute, discharging(7), "", 1},
+		}},
+		{"cancels on plug-in", time.Minute, []step{
+			{0, discharging(8), "test", 0},
+			{30 * time.Second, pluggedIn(8), "", 0},
+			{2 * time.Minute, pluggedIn(8), "", 0},
+		}},
+		{"cancels when no longer triggered", time.Minute, []step{
+			{0, discharging(8), "test", 0},
+			{10 * time.Second, discharging(50), "", 0},
+			{2 * time.Minute, discharging(50), "", 0},
+		}},
+		{"never fires on unknown charge", 0, []step{
+			{0, Info{}, "", 0},
+			{time.Minute, Info{Batteries: []Battery{{Name: "BAT0"}}}, "", 0},
+		}},
+		{"falls back to capacity", 0, []step{
+			{0, Info{Batteries: []Battery{{Name: "BAT0", Capacity: 50}}}, "", 0},
+			{time.Minute, Info{Batteries: []Battery{{Name: "BAT0", Capacity: 8}}}, "", 1},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			m := All().On(Action{
+				BelowPct: 10,
+				Name:     "test",
+				Grace:    tc.grace,
+				Do:       func() error { calls++; return nil },
+			})
+			st := newActionState(len(m.actions))
+			start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+			for _, s := range tc.steps {
+				pending, err := m.updateActions(st, s.info, start.Add(s.at))
+				if err != nil {
+					t.Fatalf("at %v: %v", s.at, err)
+				}
+				name := ""
+				if pending != nil {
+					name = pending.Name
+				}
+				if name != s.wantPending {
+					t.Errorf("at %v: pending = %q, want %q", s.at, name, s.wantPending)
+				}
+				if calls != s.wantCalls {
+					t.Errorf("at %v: %d calls, want %d", s.at, calls, s.wantCalls)
+				}
+			}
+		})
+	}
+}
+
+func TestPendingRemaining(t *testing.T) {
+	m := All().On(Action{BelowPct: 10, Name: "hibernate", Grace: time.Minute, Do: func() error { return nil }})
+	st := newActionState(len(m.actions))
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.updateActions(st, discharging(8), start)
+	pending, _ := m.updateActions(st, discharging(8), start.Add(20*time.Second))
+	if pending == nil || pending.Remaining != 40*time.Second {
+		t.Errorf("pending = %+v, want 40s remaining", pending)
+	}
+}
+
+func TestHibernate(t *testing.T) {
+	lgd, stub := logindtest.New(t)
+	m := All().On(Action{
+		Below:    2 * time.Minute,
+		BelowPct: 3,
+		Name:     "hibernate",
+		Grace:    time.Minute,
+		Do:       lgd.Hibernate,
+	})
+	st := newActionState(len(m.actions))
+	start := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := m.updateActions(st, discharging(2), start); err != nil {
+		t.Fatal(err)
+	}
+	if calls := stub.Calls(); len(calls) != 0 {
+		t.Fatalf("hibernated during the grace period: %v", calls)
+	}
+	if _, err := m.updateActions(st, discharging(2), start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if calls := stub.Calls(); !reflect.DeepEqual(calls, []string{"Manager.Hibernate"}) {
+		t.Errorf("calls = %v, want [Manager.Hibernate]", calls)
+	}
+}
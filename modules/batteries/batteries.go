@@ -0,0 +1,362 @@
+// Package batteries provides an i3bar module that aggregates every battery
+// in /sys/class/power_supply into a combined charge, time remaining and
+// power draw, while still exposing each battery individually.
+package batteries
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/notifier"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/uevent"
+)
+
+// powerSupplyDir is where the kernel exposes batteries and AC adapters.
+var powerSupplyDir = "/sys/class/power_supply"
+
+// Battery represents a single battery.
+type Battery struct {
+	// Name is the power supply name, e.g. "BAT0".
+	Name string
+	// Status is the kernel's status, e.g. "Charging", "Discharging", "Full".
+	Status string
+	// Capacity in percent, as reported by the battery.
+	Capacity int
+	// EnergyNow, EnergyFull and EnergyDesign are in Wh. They are zero for
+	// batteries that only report their capacity.
+	EnergyNow, EnergyFull, EnergyDesign float64
+	// Power currently flowing in or out of the battery, in W.
+	Power float64
+	// Peripheral is true for device batteries such as a wireless mouse,
+	// which are listed but not included in the totals.
+	Peripheral bool
+	// CycleCount is the number of charge cycles, or zero if unknown.
+	CycleCount int
+	// ChargeStart and ChargeEnd are the charge control thresholds in
+	// percent, or zero if not supported.
+	ChargeStart, ChargeEnd int
+}
+
+// Health returns the full capacity as a fraction of the design capacity,
+// or zero if unknown.
+func (b Battery) Health() float64 {
+	if b.EnergyDesign <= 0 {
+		return 0
+	}
+	return b.EnergyFull / b.EnergyDesign
+}
+
+// Conserving returns true if charging stops before the battery is full,
+// to reduce wear.
+func (b Battery) Conserving() bool {
+	return b.ChargeEnd > 0 && b.ChargeEnd < 100
+}
+
+// Info represents the combined state of all batteries.
+type Info struct {
+	// Batteries lists every battery, system and peripheral.
+	Batteries []Battery
+	// ACOnline is true if any external power supply is online.
+	ACOnline bool
+	// EnergyNow and EnergyFull are the totals of system batteries, in Wh.
+	EnergyNow, EnergyFull float64
+	// Power is the total power flowing in or out of system batteries, in W.
+	Power float64
+	// SmoothedPower is the average discharge rate, in W, if smoothing was
+	// enabled using Smoothing.
+	SmoothedPower float64
+	// Pending is the low battery action in its grace period, if any.
+	Pending *Pending
+	// Detail is true when the detail view was requested using ToggleDetail.
+	Detail bool
+	// charging is true if any system battery is charging.
+	charging bool
+}
+
+// Remaining returns the fraction of combined capacity remaining. Batteries
+// that do not report their energy are averaged from their capacity.
+func (i Info) Remaining() float64 {
+	if i.EnergyFull > 0 {
+		return i.EnergyNow / i.EnergyFull
+	}
+	total, count := 0, 0
+	for _, b := range i.Batteries {
+		if !b.Peripheral && b.Capacity > 0 {
+			total += b.Capacity
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return float64(total) / float64(count) / 100
+}
+
+// Known returns true if the charge of the system batteries is known. It is
+// false on desktops, and when the batteries could not be read.
+func (i Info) Known() bool {
+	return i.EnergyFull > 0 || i.Remaining() > 0
+}
+
+// RemainingPct returns the percentage of combined capacity remaining.
+func (i Info) RemainingPct() int {
+	return int(i.Remaining()*100 + 0.5)
+}
+
+// PluggedIn returns true if the laptop is running on external power.
+func (i Info) PluggedIn() bool {
+	return i.ACOnline || i.charging
+}
+
+// OnBattery returns true if the system is running on its batteries, i.e. it
+// has a system battery and is not plugged in. Desktops are never on battery.
+func (i Info) OnBattery() bool {
+	if i.PluggedIn() {
+		return false
+	}
+	for _, b := range i.Batteries {
+		if !b.Peripheral {
+			return true
+		}
+	}
+	return false
+}
+
+// OnBattery reads the power supplies and returns true if the system is
+// running on its batteries.
+func OnBattery() bool {
+	return read().OnBattery()
+}
+
+// Charging returns true if any battery is being charged.
+func (i Info) Charging() bool {
+	return i.charging
+}
+
+// RemainingTime returns the time until the batteries are empty at the
+// current power draw, or zero if unknown or plugged in.
+func (i Info) RemainingTime() time.Duration {
+	if i.PluggedIn() || i.Power <= 0 {
+		return 0
+	}
+	return hours(i.EnergyNow / i.Power)
+}
+
+// SmoothedRemainingTime returns the time until the batteries are empty at
+// the average discharge rate. It falls back to RemainingTime if there is
+// no discharge history.
+func (i Info) SmoothedRemainingTime() time.Duration {
+	if i.PluggedIn() {
+		return 0
+	}
+	if i.SmoothedPower <= 0 {
+		return i.RemainingTime()
+	}
+	return hours(i.EnergyNow / i.SmoothedPower)
+}
+
+// TimeToFull returns the time until the batteries are full at the current
+// charge rate, or zero if unknown or not charging.
+func (i Info) TimeToFull() time.Duration {
+	if !i.charging || i.Power <= 0 {
+		return 0
+	}
+	return hours((i.EnergyFull - i.EnergyNow) / i.Power)
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// Module represents a batteries bar module.
+type Module struct {
+	base.SimpleClickHandler
+	scheduler  *ticker
+	countdown  *ticker
+	actions    []Action
+	smoother   *smoother
+	helper     []string
+	detail     base.Value // of bool
+	refreshFn  func()
+	refreshCh  <-chan struct{}
+	outputFunc base.Value // of func(Info) bar.Output
+}
+
+// All constructs a module that combines all batteries.
+func All() *Module {
+	m := &Module{
+		scheduler: newTicker(),
+		countdown: newTicker(),
+	}
+	m.detail.Set(false)
+	m.refreshFn, m.refreshCh = notifier.New()
+	m.RefreshInterval(10 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("BAT %d%%", i.RemainingPct())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency. Polling continues
+// while the bar is paused, so that low battery actions still run.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module. Power supply uevents from the kernel trigger an
+// immediate refresh, so the refresh interval only matters for the remaining
+// time estimates and can be long.
+func (m *Module) Stream(s bar.Sink) {
+	// Without uevents (e.g. in a container), polling still works.
+	events, _ := uevent.Subscribe("power_supply")
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	st := newActionState(len(m.actions))
+	info, err := m.refresh(st)
+	for {
+		// Errors from actions are shown until the next refresh.
+		if !s.Error(err) {
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-m.scheduler.Tick():
+			info, err = m.refresh(st)
+		case <-m.countdown.Tick():
+			info, err = m.refresh(st)
+		case <-m.refreshCh:
+			info, err = m.refresh(st)
+		case _, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			info, err = m.refresh(st)
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+			err = nil
+		}
+	}
+}
+
+// refresh reads the batteries and runs any low battery actions. While an
+// action is pending, the batteries are read every second to update the
+// countdown and to cancel it as soon as external power is connected.
+func (m *Module) refresh(st *actionState) (Info, error) {
+	info := read()
+	info.Detail = m.detail.Get().(bool)
+	now := timing.Now()
+	if m.smoother != nil {
+		if !info.PluggedIn() && info.Power > 0 {
+			info.SmoothedPower = m.smoother.add(info.Power, now)
+		} else {
+			info.SmoothedPower = m.smoother.Rate
+		}
+	}
+	pending, err := m.updateActions(st, info, now)
+	info.Pending = pending
+	if pending != nil {
+		m.countdown.Every(time.Second)
+	} else {
+		m.countdown.Stop()
+	}
+	return info, err
+}
+
+// ToggleDetail switches between the summary and the detail view.
+func (m *Module) ToggleDetail() {
+	m.detail.Set(!m.detail.Get().(bool))
+	m.refreshFn()
+}
+
+// read reads all power supplies and combines them.
+func read() Info {
+	var info Info
+	supplies, _ := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	for _, dir := range supplies {
+		switch readString(dir, "type") {
+		case "Battery":
+			b := readBattery(dir)
+			info.Batteries = append(info.Batteries, b)
+			if b.Peripheral {
+				continue
+			}
+			info.EnergyNow += b.EnergyNow
+			info.EnergyFull += b.EnergyFull
+			info.Power += b.Power
+			if b.Status == "Charging" {
+				info.charging = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readString(dir, "online") == "1" {
+				info.ACOnline = true
+			}
+		}
+	}
+	return info
+}
+
+func readBattery(dir string) Battery {
+	b := Battery{
+		Name:        filepath.Base(dir),
+		Status:      readString(dir, "status"),
+		Capacity:    int(readFloat(dir, "capacity")),
+		Peripheral:  readString(dir, "scope") == "Device",
+		CycleCount:  int(readFloat(dir, "cycle_count")),
+		ChargeStart: int(readFloat(dir, "charge_control_start_threshold")),
+		ChargeEnd:   int(readFloat(dir, "charge_control_end_threshold")),
+	}
+	// Batteries report either energy (µWh) and power (µW), or charge (µAh)
+	// and current (µA) which need the voltage to be converted.
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "energy_now")); err == nil {
+		b.EnergyNow = readFloat(dir, "energy_now") / 1e6
+		b.EnergyFull = readFloat(dir, "energy_full") / 1e6
+		b.EnergyDesign = readFloat(dir, "energy_full_design") / 1e6
+		b.Power = readFloat(dir, "power_now") / 1e6
+	} else {
+		volts := readFloat(dir, "voltage_min_design") / 1e6
+		if volts == 0 {
+			volts = readFloat(dir, "voltage_now") / 1e6
+		}
+		b.EnergyNow = readFloat(dir, "charge_now") / 1e6 * volts
+		b.EnergyFull = readFloat(dir, "charge_full") / 1e6 * volts
+		b.EnergyDesign = readFloat(dir, "charge_full_design") / 1e6 * volts
+		b.Power = readFloat(dir, "current_now") / 1e6 * volts
+	}
+	if b.Power < 0 {
+		// Some drivers report a signed rate.
+		b.Power = -b.Power
+	}
+	return b
+}
+
+func readString(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func readFloat(dir, name string) float64 {
+	f, err := strconv.ParseFloat(readString(dir, name), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
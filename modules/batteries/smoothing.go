@@ -0,0 +1,85 @@
+package batteries
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxStep caps the time between two samples when computing their weight, so
+// that the history is not discarded after a suspend, a restart or a period
+// on external power.
+const maxStep = 5 * time.Minute
+
+// saveInterval limits how often the discharge history is written to disk.
+const saveInterval = 5 * time.Minute
+
+// smoother keeps an exponentially weighted moving average of the discharge
+// rate, persisted to a file so that it survives restarts.
+type smoother struct {
+	file     string
+	tau      time.Duration
+	lastSave time.Time
+	// Rate is the average discharge rate, in W.
+	Rate float64 `json:"rate"`
+	// Updated is when the last sample was added.
+	Updated time.Time `json:"updated"`
+}
+
+func loadSmoother(file string, tau time.Duration) *smoother {
+	s := &smoother{file: file, tau: tau}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		// A corrupt file just restarts the history.
+		json.Unmarshal(b, s)
+	}
+	return s
+}
+
+// add adds a discharge rate sample, and returns the new average.
+func (s *smoother) add(power float64, now time.Time) float64 {
+	if s.Rate <= 0 || s.Updated.IsZero() {
+		s.Rate = power
+	} else {
+		step := now.Sub(s.Updated)
+		if step > maxStep {
+			step = maxStep
+		}
+		alpha := 1 - math.Exp(-float64(step)/float64(s.tau))
+		s.Rate += alpha * (power - s.Rate)
+	}
+	s.Updated = now
+	if now.Sub(s.lastSave) >= saveInterval {
+		s.save()
+		s.lastSave = now
+	}
+	return s.Rate
+}
+
+// save writes the history atomically. Errors are ignored, the history is
+// kept in memory and will be written on the next attempt.
+func (s *smoother) save() {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), 0755); err != nil {
+		return
+	}
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, s.file)
+}
+
+// Smoothing estimates the remaining time from a moving average of the
+// discharge rate rather than the instantaneous one, which jumps with load.
+// Samples are weighted with the given time constant, and the history is
+// persisted to file.
+func (m *Module) Smoothing(file string, tau time.Duration) *Module {
+	m.smoother = loadSmoother(file, tau)
+	return m
+}
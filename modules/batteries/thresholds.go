@@ -0,0 +1,51 @@
+package batteries
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// ThresholdHelper configures the privileged command used to set charge
+// control thresholds, since the sysfs files are only writable by root. The
+// battery name, start and end thresholds are appended to its arguments, e.g.
+// ThresholdHelper("sudo", "/usr/local/bin/battery-thresholds").
+func (m *Module) ThresholdHelper(cmd ...string) *Module {
+	m.helper = cmd
+	return m
+}
+
+// SetThresholds sets the charge control thresholds, in percent, on every
+// system battery that supports them.
+func (m *Module) SetThresholds(start, end int) error {
+	if len(m.helper) == 0 {
+		return fmt.Errorf("no threshold helper configured")
+	}
+	defer m.refreshFn()
+	supplies, _ := filepath.Glob(filepath.Join(powerSupplyDir, "*"))
+	for _, dir := range supplies {
+		if readString(dir, "type") != "Battery" ||
+			readString(dir, "charge_control_end_threshold") == "" {
+			continue
+		}
+		args := append(append([]string{}, m.helper[1:]...),
+			filepath.Base(dir), strconv.Itoa(start), strconv.Itoa(end))
+		if err := exec.Command(m.helper[0], args...).Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToggleConservation switches between charging to full and stopping at the
+// given thresholds, e.g. ToggleConservation(75, 80) for a laptop that is
+// docked most of the day.
+func (m *Module) ToggleConservation(start, end int) error {
+	for _, b := range read().Batteries {
+		if b.Conserving() {
+			return m.SetThresholds(0, 100)
+		}
+	}
+	return m.SetThresholds(start, end)
+}
@@ -0,0 +1,58 @@
+package batteries
+
+import (
+	"sync"
+	"time"
+)
+
+// ticker is a scheduler that keeps running while the bar is paused, e.g.
+// while the screen is locked, since that is precisely when a laptop left
+// on battery needs its low battery actions.
+type ticker struct {
+	mu   sync.Mutex
+	stop chan struct{}
+	ch   chan struct{}
+}
+
+func newTicker() *ticker {
+	return &ticker{ch: make(chan struct{}, 1)}
+}
+
+// Every ticks at the given interval, replacing any previous interval.
+func (t *ticker) Every(interval time.Duration) {
+	t.Stop()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stop := make(chan struct{})
+	t.stop = stop
+	go func() {
+		tk := time.NewTicker(interval)
+		defer tk.Stop()
+		for {
+			select {
+			case <-tk.C:
+				select {
+				case t.ch <- struct{}{}:
+				default:
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops ticking.
+func (t *ticker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
+
+// Tick returns a channel that receives a value on every tick.
+func (t *ticker) Tick() <-chan struct{} {
+	return t.ch
+}
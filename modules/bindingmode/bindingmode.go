@@ -0,0 +1,82 @@
+// Package bindingmode provides an i3bar module that shows the current i3 or
+// sway binding mode, e.g. "resize". It is hidden in the default mode.
+package bindingmode
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// defaultMode is the mode in which the module is hidden.
+const defaultMode = "default"
+
+// Module represents a binding mode bar module.
+type Module struct {
+	outputFunc base.Value // of func(string) bar.Output
+}
+
+// New constructs a binding mode module.
+func New() *Module {
+	m := &Module{}
+	m.Output(func(mode string) bar.Output {
+		return outputs.Text(mode)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined
+// function. It is only called for modes other than the default one.
+func (m *Module) Output(outputFunc func(string) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := i3ipc.Connect()
+	if s.Error(err) {
+		return
+	}
+	// Query before subscribing, the connection only carries events after.
+	mode, err := conn.BindingMode()
+	if err != nil {
+		// Older i3 versions cannot be asked, but start in the default mode.
+		mode = defaultMode
+	}
+	defer conn.Close()
+	events, err := conn.Subscribe("mode")
+	if s.Error(err) {
+		return
+	}
+	outputFunc := m.outputFunc.Get().(func(string) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		if mode == defaultMode {
+			s.Output(nil)
+		} else {
+			s.Output(outputFunc(mode))
+		}
+		select {
+		case e, ok := <-events:
+			if !ok {
+				s.Error(errors.New("lost i3 IPC connection"))
+				return
+			}
+			var payload struct {
+				Change string `json:"change"`
+			}
+			if json.Unmarshal(e.Payload, &payload) == nil {
+				mode = payload.Change
+			}
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(string) bar.Output)
+		}
+	}
+}
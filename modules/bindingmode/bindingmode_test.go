@@ -0,0 +1,30 @@
+package bindingmode
+
+import (
+	"testing"
+
+	"github.com/aolwas/mybarista/i3ipc"
+	"github.com/aolwas/mybarista/i3ipc/i3ipctest"
+	"github.com/aolwas/mybarista/sinktest"
+)
+
+func TestBindingMode(t *testing.T) {
+	srv := i3ipctest.New(t)
+	srv.Reply(i3ipc.GetBindingState, `{"name":"default"}`)
+	next := sinktest.Stream(t, New()).Next
+
+	<-srv.Subscribed()
+	if s := next(); s != nil {
+		t.Errorf("shown in the default mode: %v", s)
+	}
+	srv.Event(i3ipc.ModeEvent, `{"change":"resize","pango_markup":false}`)
+	if s := next(); len(s) == 0 {
+		t.Error("hidden in resize mode")
+	} else if text, _ := s[0].Content(); text != "resize" {
+		t.Errorf("shows %q, want resize", text)
+	}
+	srv.Event(i3ipc.ModeEvent, `{"change":"default","pango_markup":false}`)
+	if s := next(); s != nil {
+		t.Errorf("shown after returning to the default mode: %v", s)
+	}
+}
@@ -0,0 +1,297 @@
+// Package bluetooth provides an i3bar module that shows the bluetooth
+// adapter state and connected devices from BlueZ over D-Bus, and powers the
+// adapter and connects devices on click.
+package bluetooth
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+)
+
+const (
+	bluezDest    = "org.bluez"
+	adapterIface = bluezDest + ".Adapter1"
+	deviceIface  = bluezDest + ".Device1"
+	batteryIface = bluezDest + ".Battery1"
+	objectIface  = "org.freedesktop.DBus.ObjectManager"
+	propsIface   = "org.freedesktop.DBus.Properties"
+)
+
+// Device represents a paired bluetooth device.
+type Device struct {
+	// Name is the device alias, e.g. "WH-1000XM3".
+	Name string
+	// Address is the MAC address.
+	Address string
+	// Connected is true if the device is connected.
+	Connected bool
+	// Battery is the battery percentage, or -1 if not reported.
+	Battery int
+	path    dbus.ObjectPath
+}
+
+// Info represents the bluetooth state.
+type Info struct {
+	// Available is true if there is a bluetooth adapter.
+	Available bool
+	// Powered is true if the adapter is powered on.
+	Powered bool
+	// Devices lists the paired devices, sorted by name.
+	Devices []Device
+	// Selected is the index in Devices of the device selected by
+	// scrolling, or -1 if none is selected.
+	Selected int
+	adapter  dbus.ObjectPath
+}
+
+// Connected returns the connected devices.
+func (i Info) Connected() []Device {
+	var connected []Device
+	for _, d := range i.Devices {
+		if d.Connected {
+			connected = append(connected, d)
+		}
+	}
+	return connected
+}
+
+// Selection returns the selected device, if any.
+func (i Info) Selection() (Device, bool) {
+	if i.Selected < 0 || i.Selected >= len(i.Devices) {
+		return Device{}, false
+	}
+	return i.Devices[i.Selected], true
+}
+
+// Module represents a bluetooth bar module.
+type Module struct {
+	base.SimpleClickHandler
+	info       base.ErrorValue // of Info
+	selected   base.Value      // of string, the selected address
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a bluetooth module. Left click toggles the adapter power,
+// scrolling selects a paired device, and middle click connects or
+// disconnects the selected device.
+func New() *Module {
+	m := &Module{}
+	m.selected.Set("")
+	m.Output(func(i Info) bar.Output {
+		if !i.Available {
+			return nil
+		}
+		if !i.Powered {
+			return outputs.Text("BT off")
+		}
+		if d, ok := i.Selection(); ok {
+			return outputs.Textf("BT > %s", d.Name)
+		}
+		var names []string
+		for _, d := range i.Connected() {
+			names = append(names, d.Name)
+		}
+		return outputs.Textf("BT %s", strings.Join(names, ", "))
+	})
+	m.OnClick(m.click)
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+func (m *Module) click(e bar.Event) {
+	v, err := m.info.Get()
+	if err != nil || v == nil {
+		return
+	}
+	i := v.(Info)
+	switch e.Button {
+	case bar.ButtonLeft:
+		m.info.Error(m.setPowered(i, !i.Powered))
+	case bar.ScrollUp, bar.ScrollLeft:
+		m.selectDevice(i, -1)
+	case bar.ScrollDown, bar.ScrollRight:
+		m.selectDevice(i, 1)
+	case bar.ButtonMiddle:
+		if d, ok := i.Selection(); ok {
+			// Connecting takes several seconds, the device's Connected
+			// property signals the result.
+			go func() { m.info.Error(toggleConnection(d)) }()
+		}
+	}
+}
+
+// selectDevice moves the selection by delta, with an extra position past
+// the last device for no selection, which shows the connected devices.
+func (m *Module) selectDevice(i Info, delta int) {
+	if len(i.Devices) == 0 {
+		return
+	}
+	count := len(i.Devices) + 1
+	idx := i.Selected
+	if idx < 0 {
+		idx = len(i.Devices)
+	}
+	idx = (idx + delta + count) % count
+	address := ""
+	if idx < len(i.Devices) {
+		address = i.Devices[idx].Address
+	}
+	m.selected.Set(address)
+	i.Selected = idx
+	if idx == len(i.Devices) {
+		i.Selected = -1
+	}
+	m.info.Set(i)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := dbus.SystemBus()
+	if s.Error(err) {
+		return
+	}
+	// Devices appearing and disappearing are signalled by the object
+	// manager, and connection and battery changes by their properties.
+	for _, rule := range []string{
+		"type='signal',sender='" + bluezDest + "',interface='" + objectIface + "'",
+		"type='signal',sender='" + bluezDest + "',interface='" + propsIface + "'",
+		// BlueZ starting or stopping.
+		"type='signal',interface='org.freedesktop.DBus',member='NameOwnerChanged',arg0='" + bluezDest + "'",
+	} {
+		err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err
+		if s.Error(err) {
+			return
+		}
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	m.refresh(conn)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case sig := <-signals:
+			// The connection is shared, and carries signals for other
+			// modules too.
+			if relevant(sig) {
+				m.refresh(conn)
+			}
+		}
+	}
+}
+
+// relevant returns true for signals that may change the bluetooth state.
+func relevant(sig *dbus.Signal) bool {
+	switch sig.Name {
+	case objectIface + ".InterfacesAdded", objectIface + ".InterfacesRemoved":
+		// BlueZ exports its object manager at the root.
+		return sig.Path == "/"
+	case propsIface + ".PropertiesChanged":
+		return strings.HasPrefix(string(sig.Path), "/org/bluez/")
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		return len(sig.Body) > 0 && sig.Body[0] == bluezDest
+	}
+	return false
+}
+
+func (m *Module) refresh(conn *dbus.Conn) {
+	i, err := read(conn, m.selected.Get().(string))
+	if m.info.Error(err) {
+		return
+	}
+	m.info.Set(i)
+}
+
+// read builds the state from all objects managed by BlueZ.
+func read(conn *dbus.Conn, selected string) (Info, error) {
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	err := conn.Object(bluezDest, "/").
+		Call(objectIface+".GetManagedObjects", 0).Store(&objects)
+	if e, ok := err.(dbus.Error); ok && e.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+		// No bluetooth on this machine, or bluetoothd is not running.
+		return Info{Selected: -1}, nil
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	i := Info{Selected: -1}
+	for path, ifaces := range objects {
+		// With several adapters, consistently use the first one (hci0).
+		if adapter, ok := ifaces[adapterIface]; ok && (!i.Available || path < i.adapter) {
+			i.Available = true
+			i.adapter = path
+			i.Powered, _ = adapter["Powered"].Value().(bool)
+		}
+		dev, ok := ifaces[deviceIface]
+		if !ok {
+			continue
+		}
+		if paired, _ := dev["Paired"].Value().(bool); !paired {
+			continue
+		}
+		d := Device{Battery: -1, path: path}
+		d.Name, _ = dev["Alias"].Value().(string)
+		d.Address, _ = dev["Address"].Value().(string)
+		d.Connected, _ = dev["Connected"].Value().(bool)
+		if battery, ok := ifaces[batteryIface]; ok {
+			if pct, ok := battery["Percentage"].Value().(byte); ok {
+				d.Battery = int(pct)
+			}
+		}
+		i.Devices = append(i.Devices, d)
+	}
+	sort.Slice(i.Devices, func(a, b int) bool {
+		return i.Devices[a].Name < i.Devices[b].Name
+	})
+	for idx, d := range i.Devices {
+		if selected != "" && d.Address == selected {
+			i.Selected = idx
+		}
+	}
+	return i, nil
+}
+
+func (m *Module) setPowered(i Info, powered bool) error {
+	if !i.Available {
+		return nil
+	}
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	return conn.Object(bluezDest, i.adapter).Call(propsIface+".Set", 0,
+		adapterIface, "Powered", dbus.MakeVariant(powered)).Err
+}
+
+func toggleConnection(d Device) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	method := deviceIface + ".Connect"
+	if d.Connected {
+		method = deviceIface + ".Disconnect"
+	}
+	return conn.Object(bluezDest, d.path).Call(method, 0).Err
+}
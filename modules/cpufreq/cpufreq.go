@@ -0,0 +1,192 @@
+// Package cpufreq provides an i3bar module that shows the average CPU
+// frequency and scaling governor, and flags thermal throttling.
+package cpufreq
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+)
+
+// cpuDir is where the kernel exposes per-cpu information.
+var cpuDir = "/sys/devices/system/cpu"
+
+// throttleHold is how long Throttled stays set after the last increase,
+// so that brief throttling is not missed between refreshes.
+const throttleHold = time.Minute
+
+// Info represents the current CPU frequency state.
+type Info struct {
+	// Frequency is the average current frequency across all CPUs.
+	Frequency unit.Frequency
+	// Governor is the scaling governor of the first CPU, e.g. "powersave".
+	Governor string
+	// EPP is the energy performance preference of the first CPU,
+	// e.g. "balance_power", if supported by the driver.
+	EPP string
+	// Throttled is true if any thermal throttle counter increased within
+	// the last minute.
+	Throttled bool
+}
+
+// Module represents a cpufreq bar module.
+type Module struct {
+	scheduler  *timing.Scheduler
+	governors  []string
+	helper     []string
+	outputFunc base.Value      // of func(Info) bar.Output
+	clicked    base.ErrorValue // set after running the helper
+}
+
+// New constructs a cpufreq module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	m.RefreshInterval(3 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.1fGHz %s", i.Frequency.Gigahertz(), i.Governor)
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Governors configures the governors to cycle through on click. If not set,
+// all available governors are used.
+func (m *Module) Governors(governors ...string) *Module {
+	m.governors = governors
+	return m
+}
+
+// Helper configures the privileged command used to set the governor. The
+// new governor is appended to its arguments, e.g.
+// Helper("sudo", "cpupower", "frequency-set", "-g").
+// Clicking does nothing until a helper is configured.
+func (m *Module) Helper(cmd ...string) *Module {
+	m.helper = cmd
+	return m
+}
+
+// Click switches to the next governor on left click.
+func (m *Module) Click(e bar.Event) {
+	if e.Button != bar.ButtonLeft || len(m.helper) == 0 {
+		return
+	}
+	governors := m.governors
+	if len(governors) == 0 {
+		governors = strings.Fields(readString(filepath.Join(cpuDir, "cpu0/cpufreq/scaling_available_governors")))
+	}
+	if len(governors) == 0 {
+		return
+	}
+	current := readString(filepath.Join(cpuDir, "cpu0/cpufreq/scaling_governor"))
+	next := governors[0]
+	for i, g := range governors {
+		if g == current {
+			next = governors[(i+1)%len(governors)]
+		}
+	}
+	args := append(append([]string{}, m.helper[1:]...), next)
+	if err := exec.Command(m.helper[0], args...).Run(); !m.clicked.Error(err) {
+		// Show the new governor right away.
+		m.clicked.Set(next)
+	}
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	lastThrottles := throttleCount()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextClick := m.clicked.Next()
+	var lastThrottled time.Time
+	var info Info
+	refresh := func() {
+		now := timing.Now()
+		if throttles := throttleCount(); throttles > lastThrottles {
+			lastThrottled = now
+			lastThrottles = throttles
+		}
+		info = Info{
+			Frequency: averageFrequency(),
+			Governor:  readString(filepath.Join(cpuDir, "cpu0/cpufreq/scaling_governor")),
+			EPP:       readString(filepath.Join(cpuDir, "cpu0/cpufreq/energy_performance_preference")),
+			Throttled: !lastThrottled.IsZero() && now.Sub(lastThrottled) < throttleHold,
+		}
+	}
+	refresh()
+	s.Output(outputFunc(info))
+	for {
+		select {
+		case <-m.scheduler.Tick():
+			refresh()
+		case <-nextClick:
+			nextClick = m.clicked.Next()
+			// Errors from the helper are shown until the next refresh.
+			if _, err := m.clicked.Get(); s.Error(err) {
+				continue
+			}
+			refresh()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+		s.Output(outputFunc(info))
+	}
+}
+
+func averageFrequency() unit.Frequency {
+	files, _ := filepath.Glob(filepath.Join(cpuDir, "cpu[0-9]*/cpufreq/scaling_cur_freq"))
+	total, count := 0.0, 0
+	for _, f := range files {
+		khz, err := strconv.ParseFloat(readString(f), 64)
+		if err != nil {
+			continue
+		}
+		total += khz
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+	return unit.Frequency(total/float64(count)) * unit.Kilohertz
+}
+
+// throttleCount returns the sum of all core and package thermal throttle
+// counters. Only increases matter, so the sum is enough.
+func throttleCount() uint64 {
+	files, _ := filepath.Glob(filepath.Join(cpuDir, "cpu[0-9]*/thermal_throttle/*_throttle_count"))
+	var total uint64
+	for _, f := range files {
+		if n, err := strconv.ParseUint(readString(f), 10, 64); err == nil {
+			total += n
+		}
+	}
+	return total
+}
+
+func readString(file string) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
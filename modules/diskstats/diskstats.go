@@ -0,0 +1,197 @@
+// Package diskstats provides an i3bar module that shows disk IO rates and
+// utilisation read from /proc/diskstats, either for a single disk or for
+// whichever disk is currently the busiest.
+package diskstats
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/modules/diskio"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+)
+
+// Sectors in /proc/diskstats are always 512 bytes, regardless of the
+// actual sector size of the device.
+const sectorSize = 512 * unit.Byte
+
+// Info represents the IO activity of a single disk.
+type Info struct {
+	// Disk is the name of the device, e.g. "sda" or "nvme0n1".
+	Disk string
+	diskio.IO
+	// Utilisation is the fraction of time the disk was busy doing IO
+	// (io_ticks), from 0 to 1.
+	Utilisation float64
+}
+
+// Module represents a disk activity bar module.
+type Module struct {
+	base.SimpleClickHandler
+	disk       string
+	scheduler  *timing.Scheduler
+	outputFunc base.Value // of func(Info) bar.Output
+}
+
+// Disk constructs a module that shows IO activity for the named disk.
+func Disk(disk string) *Module {
+	m := &Module{disk: disk, scheduler: timing.NewScheduler()}
+	m.RefreshInterval(3 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%s: %s/s", i.Disk, outputs.IByterate(i.Total()))
+	})
+	return m
+}
+
+// Busiest constructs a module that shows IO activity for the disk with
+// the highest utilisation since the last refresh.
+func Busiest() *Module {
+	return Disk("")
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	prev, err := readDiskstats()
+	if s.Error(err) {
+		return
+	}
+	lastRead := timing.Now()
+	var info Info
+	var haveInfo bool
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		select {
+		case <-m.scheduler.Tick():
+			stats, err := readDiskstats()
+			if s.Error(err) {
+				return
+			}
+			now := timing.Now()
+			info, haveInfo = m.pick(prev, stats, now.Sub(lastRead))
+			prev, lastRead = stats, now
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+		if haveInfo {
+			s.Output(outputFunc(info))
+		} else {
+			s.Output(nil)
+		}
+	}
+}
+
+// pick computes the activity of each disk between two readings, and returns
+// the configured disk, or the busiest one if no disk was configured.
+func (m *Module) pick(prev, cur map[string]counters, elapsed time.Duration) (Info, bool) {
+	var best Info
+	found := false
+	for disk, c := range cur {
+		if m.disk != "" && disk != m.disk {
+			continue
+		}
+		if m.disk == "" && !isWholeDisk(disk) {
+			continue
+		}
+		p, ok := prev[disk]
+		if !ok {
+			continue
+		}
+		i := c.since(p, elapsed)
+		i.Disk = disk
+		if !found || i.Utilisation > best.Utilisation ||
+			(i.Utilisation == best.Utilisation && i.Total() > best.Total()) {
+			best = i
+			found = true
+		}
+	}
+	return best, found
+}
+
+// counters holds the cumulative values read from /proc/diskstats.
+type counters struct {
+	sectorsRead, sectorsWritten uint64
+	ioTicks                     time.Duration
+}
+
+func (c counters) since(p counters, elapsed time.Duration) Info {
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return Info{}
+	}
+	rate := func(sectors uint64) unit.Datarate {
+		bytes := (unit.Datasize(sectors) * sectorSize).Bytes()
+		return unit.Datarate(bytes/secs) * unit.BytePerSecond
+	}
+	util := float64(c.ioTicks-p.ioTicks) / float64(elapsed)
+	if util > 1 {
+		util = 1
+	}
+	return Info{
+		IO: diskio.IO{
+			Input:  rate(c.sectorsRead - p.sectorsRead),
+			Output: rate(c.sectorsWritten - p.sectorsWritten),
+		},
+		Utilisation: util,
+	}
+}
+
+// isWholeDisk returns true for block devices that are neither partitions
+// nor virtual loop/ram devices, so that the busiest disk is not shadowed
+// by one of its own partitions.
+func isWholeDisk(name string) bool {
+	if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+		return false
+	}
+	_, err := os.Stat("/sys/block/" + name)
+	return err == nil
+}
+
+func readDiskstats() (map[string]counters, error) {
+	f, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats := map[string]counters{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// See https://www.kernel.org/doc/Documentation/iostats.txt
+		fields := strings.Fields(s.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		read, err1 := strconv.ParseUint(fields[5], 10, 64)
+		written, err2 := strconv.ParseUint(fields[9], 10, 64)
+		ticks, err3 := strconv.ParseUint(fields[12], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		stats[fields[2]] = counters{
+			sectorsRead:    read,
+			sectorsWritten: written,
+			ioTicks:        time.Duration(ticks) * time.Millisecond,
+		}
+	}
+	return stats, s.Err()
+}
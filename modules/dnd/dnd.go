@@ -0,0 +1,227 @@
+// Package dnd provides an i3bar module that shows and toggles the
+// do-not-disturb state of the notification daemon, dunst or mako, and can
+// turn it on automatically during quiet hours.
+package dnd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/notify"
+)
+
+// Info represents the do-not-disturb state.
+type Info struct {
+	// Paused is true while notifications are held back.
+	Paused bool
+	// Waiting is the number of notifications held back while paused.
+	Waiting int
+	// Quiet is true during the configured quiet hours.
+	Quiet bool
+}
+
+// daemon is a notification daemon that can be paused.
+type daemon interface {
+	// read returns whether notifications are paused, and how many wait.
+	read() (paused bool, waiting int, err error)
+	// setPaused pauses or resumes notifications.
+	setPaused(paused bool) error
+	// signals returns a match rule for signals sent on state changes.
+	signals() string
+	// relevant returns true if a signal received on the shared session bus
+	// connection is one of those signals.
+	relevant(sig *dbus.Signal) bool
+}
+
+// Module represents a do-not-disturb bar module.
+type Module struct {
+	base.SimpleClickHandler
+	scheduler  *timing.Scheduler
+	toggled    base.Value // of bool, set when toggled with a click
+	autoPaused bool       // paused by the quiet hours, only used by Stream
+	quietFrom  time.Duration
+	quietTo    time.Duration
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a do-not-disturb module. Left click toggles the state.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	m.toggled.Set(false)
+	// Waiting notifications are not signalled, and quiet hours are checked
+	// on refresh.
+	m.RefreshInterval(time.Minute)
+	m.Output(func(i Info) bar.Output {
+		if !i.Paused {
+			return outputs.Text("notifications")
+		}
+		return outputs.Textf("dnd (%d)", i.Waiting)
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.Toggle()
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// QuietHours pauses notifications automatically between from and to, given
+// as times of day, e.g. QuietHours(22*time.Hour, 7*time.Hour+30*time.Minute).
+// Notifications are resumed at the end of the quiet hours, unless they were
+// already paused when the quiet hours started, or were toggled with a click
+// in the meantime.
+func (m *Module) QuietHours(from, to time.Duration) *Module {
+	m.quietFrom, m.quietTo = from, to
+	return m
+}
+
+// quiet returns true if now is within the quiet hours.
+func (m *Module) quiet(now time.Time) bool {
+	if m.quietFrom == m.quietTo {
+		return false
+	}
+	y, mo, d := now.Date()
+	t := now.Sub(time.Date(y, mo, d, 0, 0, 0, 0, now.Location()))
+	if m.quietFrom < m.quietTo {
+		return t >= m.quietFrom && t < m.quietTo
+	}
+	// Quiet hours span midnight.
+	return t >= m.quietFrom || t < m.quietTo
+}
+
+// Toggle pauses or resumes notifications.
+func (m *Module) Toggle() {
+	d, err := connect()
+	if m.info.Error(err) {
+		return
+	}
+	paused, _, err := d.read()
+	if m.info.Error(err) {
+		return
+	}
+	if m.info.Error(d.setPaused(!paused)) {
+		return
+	}
+	m.toggled.Set(true)
+	m.refresh(d)
+}
+
+// connect returns the running notification daemon.
+func connect() (daemon, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	name, err := notify.ServerName()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "dunst":
+		return &dunst{conn}, nil
+	case "mako":
+		return &mako{conn}, nil
+	}
+	return nil, fmt.Errorf("do not disturb is not supported for %q", name)
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	d, err := connect()
+	if s.Error(err) {
+		return
+	}
+	conn, err := dbus.SessionBus()
+	if s.Error(err) {
+		return
+	}
+	err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, d.signals()).Err
+	if s.Error(err) {
+		return
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	wasQuiet := false
+	m.refresh(d)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case sig := <-signals:
+			if d.relevant(sig) {
+				m.refresh(d)
+			}
+		case <-m.scheduler.Tick():
+			// Only act on the edges of the quiet hours, so that toggling
+			// during them sticks.
+			if quiet := m.quiet(timing.Now()); quiet != wasQuiet {
+				wasQuiet = quiet
+				m.quietEdge(d, quiet)
+			}
+			m.refresh(d)
+		}
+	}
+}
+
+// quietEdge pauses notifications at the start of the quiet hours, and
+// resumes them at the end if they were paused by the quiet hours and not
+// toggled since.
+func (m *Module) quietEdge(d daemon, quiet bool) {
+	if quiet {
+		paused, _, err := d.read()
+		if m.info.Error(err) || paused {
+			return
+		}
+		if !m.info.Error(d.setPaused(true)) {
+			m.autoPaused = true
+			m.toggled.Set(false)
+		}
+		return
+	}
+	if m.autoPaused && !m.toggled.Get().(bool) {
+		m.info.Error(d.setPaused(false))
+	}
+	m.autoPaused = false
+}
+
+func (m *Module) refresh(d daemon) {
+	paused, waiting, err := d.read()
+	if m.info.Error(err) {
+		return
+	}
+	i := Info{Paused: paused, Waiting: waiting, Quiet: m.quiet(timing.Now())}
+	if old, err := m.info.Get(); err == nil && old == i {
+		return
+	}
+	m.info.Set(i)
+}
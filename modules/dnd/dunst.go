@@ -0,0 +1,52 @@
+package dnd
+
+import (
+	"github.com/godbus/dbus"
+)
+
+const (
+	dunstDest  = "org.freedesktop.Notifications"
+	dunstPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	dunstIface = "org.dunstproject.cmd0"
+)
+
+// dunst pauses notifications with the "paused" property of its control
+// interface.
+type dunst struct {
+	conn *dbus.Conn
+}
+
+func (d *dunst) read() (bool, int, error) {
+	obj := d.conn.Object(dunstDest, dunstPath)
+	p, err := obj.GetProperty(dunstIface + ".paused")
+	if err != nil {
+		return false, 0, err
+	}
+	paused, _ := p.Value().(bool)
+	// Older versions of dunst do not report the waiting count.
+	waiting := 0
+	if w, err := obj.GetProperty(dunstIface + ".waitingLength"); err == nil {
+		n, _ := w.Value().(uint32)
+		waiting = int(n)
+	}
+	return paused, waiting, nil
+}
+
+func (d *dunst) setPaused(paused bool) error {
+	return d.conn.Object(dunstDest, dunstPath).Call(
+		"org.freedesktop.DBus.Properties.Set", 0,
+		dunstIface, "paused", dbus.MakeVariant(paused)).Err
+}
+
+func (d *dunst) signals() string {
+	// Paused state changes are signalled as property changes, and new
+	// notifications as calls to Notify, which are not signals, so the
+	// waiting count is polled.
+	return "type='signal',path='" + string(dunstPath) + "'," +
+		"interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'"
+}
+
+func (d *dunst) relevant(sig *dbus.Signal) bool {
+	return sig.Name == "org.freedesktop.DBus.Properties.PropertiesChanged" &&
+		sig.Path == dunstPath && len(sig.Body) > 0 && sig.Body[0] == dunstIface
+}
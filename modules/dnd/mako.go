@@ -0,0 +1,86 @@
+package dnd
+
+import (
+	"github.com/godbus/dbus"
+)
+
+const (
+	makoDest  = "org.freedesktop.Notifications"
+	makoPath  = dbus.ObjectPath("/fr/emersion/Mako")
+	makoIface = "fr.emersion.Mako"
+)
+
+// makoMode is the mako mode used for do not disturb. It must be defined in
+// the mako config, typically with "invisible=1", e.g.
+//
+//	[mode=do-not-disturb]
+//	invisible=1
+const makoMode = "do-not-disturb"
+
+// mako pauses notifications by enabling the do-not-disturb mode, using the
+// mode set API of mako 1.9 and later.
+type mako struct {
+	conn *dbus.Conn
+}
+
+// modes returns the enabled modes.
+func (m *mako) modes() ([]string, error) {
+	var modes []string
+	err := m.conn.Object(makoDest, makoPath).Call(makoIface+".ListModes", 0).Store(&modes)
+	return modes, err
+}
+
+func (m *mako) read() (bool, int, error) {
+	modes, err := m.modes()
+	if err != nil {
+		return false, 0, err
+	}
+	paused := false
+	for _, mode := range modes {
+		if mode == makoMode {
+			paused = true
+		}
+	}
+	if !paused {
+		return false, 0, nil
+	}
+	// Invisible notifications are kept, and listed.
+	var list []map[string]dbus.Variant
+	err = m.conn.Object(makoDest, makoPath).Call(makoIface+".ListNotifications", 0).Store(&list)
+	if err != nil {
+		return true, 0, nil
+	}
+	return true, len(list), nil
+}
+
+// setPaused adds or removes the do-not-disturb mode, leaving any other
+// enabled mode alone.
+func (m *mako) setPaused(paused bool) error {
+	modes, err := m.modes()
+	if err != nil {
+		return err
+	}
+	var updated []string
+	for _, mode := range modes {
+		if mode != makoMode {
+			updated = append(updated, mode)
+		}
+	}
+	if paused {
+		updated = append(updated, makoMode)
+	}
+	if updated == nil {
+		updated = []string{}
+	}
+	return m.conn.Object(makoDest, makoPath).Call(makoIface+".SetModes", 0, updated).Err
+}
+
+func (m *mako) signals() string {
+	// mako does not signal mode changes, only closed notifications, so the
+	// state is mostly polled.
+	return "type='signal',interface='org.freedesktop.Notifications',member='NotificationClosed'"
+}
+
+func (m *mako) relevant(sig *dbus.Signal) bool {
+	return sig.Name == "org.freedesktop.Notifications.NotificationClosed"
+}
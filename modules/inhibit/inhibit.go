@@ -0,0 +1,156 @@
+// Package inhibit provides an i3bar module that toggles a logind idle
+// inhibitor, to keep the screen on during presentations or long builds.
+package inhibit
+
+import (
+	"os"
+	"time"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/logind"
+)
+
+// what is the set of operations inhibited.
+const what = "idle:sleep"
+
+// Info represents the state of the inhibitor.
+type Info struct {
+	// Active is true while the inhibitor lock is held.
+	Active bool
+	// Remaining is the time until the lock is released automatically,
+	// or zero if it is held until clicked again.
+	Remaining time.Duration
+}
+
+// Module represents an idle inhibitor bar module.
+type Module struct {
+	base.SimpleClickHandler
+	why        string
+	release    time.Duration
+	countdown  *timing.Scheduler
+	state      base.ErrorValue // of state
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// state holds the inhibitor lock. Closing the file releases it; should the
+// bar exit without calling Release, the kernel closes it, so the lock never
+// outlives the bar.
+type state struct {
+	file     *os.File
+	deadline time.Time
+}
+
+// New constructs an idle inhibitor module.
+func New() *Module {
+	m := &Module{
+		why:       "Inhibited from the status bar",
+		countdown: timing.NewScheduler(),
+	}
+	m.state.Set(state{})
+	m.Output(func(i Info) bar.Output {
+		if i.Active {
+			return outputs.Text("awake")
+		}
+		return outputs.Text("idle")
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.Toggle()
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Why configures the reason shown by e.g. "systemd-inhibit --list".
+func (m *Module) Why(why string) *Module {
+	m.why = why
+	return m
+}
+
+// AutoRelease configures the inhibitor to be released automatically after
+// the given duration, with a countdown shown on the bar.
+func (m *Module) AutoRelease(after time.Duration) *Module {
+	m.release = after
+	return m
+}
+
+// Toggle takes the inhibitor lock, or releases it if already held.
+func (m *Module) Toggle() {
+	st, _ := m.state.Get()
+	if s, ok := st.(state); ok && s.file != nil {
+		m.Release()
+		return
+	}
+	lgd, err := logind.System()
+	if m.state.Error(err) {
+		return
+	}
+	f, err := lgd.Inhibit(what, "mybarista", m.why, "block")
+	if m.state.Error(err) {
+		return
+	}
+	s := state{file: f}
+	if m.release > 0 {
+		s.deadline = timing.Now().Add(m.release)
+		m.countdown.Every(time.Second)
+	}
+	m.state.Set(s)
+}
+
+// Release releases the inhibitor lock, if held. It is safe to call from any
+// goroutine, e.g. when the bar shuts down.
+func (m *Module) Release() {
+	m.countdown.Stop()
+	st, _ := m.state.Get()
+	if s, ok := st.(state); ok && s.file != nil {
+		s.file.Close()
+	}
+	m.state.Set(state{})
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextState := m.state.Next()
+	for {
+		st, err := m.state.Get()
+		if !s.Error(err) {
+			s.Output(outputFunc(m.info(st.(state))))
+		}
+		select {
+		case <-nextState:
+			nextState = m.state.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case <-m.countdown.Tick():
+			if st, _ := m.state.Get(); st != nil {
+				if d := st.(state).deadline; !d.IsZero() && !timing.Now().Before(d) {
+					m.Release()
+				}
+			}
+		}
+	}
+}
+
+func (m *Module) info(s state) Info {
+	i := Info{Active: s.file != nil}
+	if i.Active && !s.deadline.IsZero() {
+		i.Remaining = s.deadline.Sub(timing.Now())
+		if i.Remaining < 0 {
+			i.Remaining = 0
+		}
+	}
+	return i
+}
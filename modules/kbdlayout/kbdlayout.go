@@ -0,0 +1,130 @@
+// Package kbdlayout provides an i3bar module that shows the active keyboard
+// layout and cycles through the configured layouts on click. Under sway,
+// layouts come from the IPC socket and changes are signalled by input
+// events. Under X11, the active XKB group is read, watched and switched with
+// xkb-switch, which must be installed.
+package kbdlayout
+
+import (
+	"errors"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// Info represents the keyboard layout state.
+type Info struct {
+	// Layout is the short name of the active layout, e.g. "fr".
+	Layout string
+	// Layouts lists the short names of all configured layouts.
+	Layouts []string
+}
+
+// backend reads and switches layouts.
+type backend interface {
+	// read returns the current state.
+	read() (Info, error)
+	// next switches to the next layout.
+	next() error
+	// changes returns a channel signalled on layout changes.
+	changes() (<-chan struct{}, error)
+}
+
+// Module represents a keyboard layout bar module.
+type Module struct {
+	base.SimpleClickHandler
+	backend    backend
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a keyboard layout module for the running session.
+func New() *Module {
+	var b backend = &x11{}
+	if i3ipc.IsSway() {
+		b = &sway{}
+	}
+	m := &Module{backend: b}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text(i.Layout)
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.Next()
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Next switches to the next layout.
+func (m *Module) Next() {
+	if m.info.Error(m.backend.next()) {
+		return
+	}
+	m.refresh()
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	changes, err := m.backend.changes()
+	if s.Error(err) {
+		return
+	}
+	m.refresh()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case _, ok := <-changes:
+			if !ok {
+				// Lost the IPC connection, most likely sway is exiting,
+				// or xkb-switch died.
+				s.Error(errors.New("lost track of keyboard layout changes"))
+				return
+			}
+			m.refresh()
+			continue
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) refresh() {
+	i, err := m.backend.read()
+	if m.info.Error(err) {
+		return
+	}
+	if old, err := m.info.Get(); err == nil && old != nil && equal(old.(Info), i) {
+		return
+	}
+	m.info.Set(i)
+}
+
+func equal(a, b Info) bool {
+	if a.Layout != b.Layout || len(a.Layouts) != len(b.Layouts) {
+		return false
+	}
+	for idx := range a.Layouts {
+		if a.Layouts[idx] != b.Layouts[idx] {
+			return false
+		}
+	}
+	return true
+}
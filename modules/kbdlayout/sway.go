@@ -0,0 +1,162 @@
+package kbdlayout
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// sway reads layouts from the first keyboard reported by sway.
+type sway struct {
+	mu   sync.Mutex
+	conn *i3ipc.Conn
+}
+
+type swayInput struct {
+	Type        string   `json:"type"`
+	ActiveName  string   `json:"xkb_active_layout_name"`
+	LayoutNames []string `json:"xkb_layout_names"`
+}
+
+// do runs fn on a shared connection, reconnecting after errors.
+func (s *sway) do(fn func(*i3ipc.Conn) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := i3ipc.Connect()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	err := fn(s.conn)
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *sway) read() (Info, error) {
+	var inputs []swayInput
+	err := s.do(func(c *i3ipc.Conn) error {
+		return c.Request(i3ipc.GetInputs, "", &inputs)
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	var i Info
+	for _, in := range inputs {
+		if in.Type != "keyboard" || len(in.LayoutNames) == 0 {
+			continue
+		}
+		i.Layout = shortName(in.ActiveName)
+		for _, name := range in.LayoutNames {
+			i.Layouts = append(i.Layouts, shortName(name))
+		}
+		break
+	}
+	return i, nil
+}
+
+func (s *sway) next() error {
+	return s.do(func(c *i3ipc.Conn) error {
+		return c.Command("input type:keyboard xkb_switch_layout next")
+	})
+}
+
+func (s *sway) changes() (<-chan struct{}, error) {
+	conn, err := i3ipc.Connect()
+	if err != nil {
+		return nil, err
+	}
+	events, err := conn.Subscribe("input")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		for e := range events {
+			var payload struct {
+				Change string `json:"change"`
+			}
+			if json.Unmarshal(e.Payload, &payload) != nil {
+				continue
+			}
+			switch payload.Change {
+			case "xkb_layout", "xkb_keymap", "added", "removed":
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// evdevList maps xkb layout descriptions to their short names.
+var evdevList = "/usr/share/X11/xkb/rules/evdev.lst"
+
+var (
+	shortNamesOnce sync.Once
+	shortNames     map[string]string
+)
+
+// shortName returns the short name of a layout from its description, e.g.
+// "fr" for "French", since sway only reports descriptions.
+func shortName(description string) string {
+	shortNamesOnce.Do(loadShortNames)
+	if name, ok := shortNames[description]; ok {
+		return name
+	}
+	if len(description) > 2 {
+		return strings.ToLower(description[:2])
+	}
+	return strings.ToLower(description)
+}
+
+// loadShortNames parses the layout and variant sections of evdev.lst:
+//
+//	! layout
+//	  us              English (US)
+//	! variant
+//	  azerty          fr: French (AZERTY)
+func loadShortNames() {
+	shortNames = map[string]string{}
+	f, err := os.Open(evdevList)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	section := ""
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "!") {
+			section = strings.TrimSpace(line[1:])
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		description := strings.TrimSpace(strings.TrimPrefix(line, "  "+fields[0]))
+		switch section {
+		case "layout":
+			shortNames[description] = fields[0]
+		case "variant":
+			// Variants are described as "fr: French (AZERTY)", and shown
+			// with the name of their layout.
+			if idx := strings.Index(description, ": "); idx > 0 {
+				shortNames[description[idx+2:]] = description[:idx]
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package kbdlayout
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+)
+
+// x11 uses xkb-switch, which reads and switches the active XKB group. The
+// configured layouts and variants are left untouched, so group switching
+// hotkeys keep working and are tracked too.
+type x11 struct{}
+
+func (x *x11) read() (Info, error) {
+	out, err := exec.Command("xkb-switch", "-p").Output()
+	if err != nil {
+		return Info{}, err
+	}
+	i := Info{Layout: layoutName(string(out))}
+	out, err = exec.Command("xkb-switch", "-l").Output()
+	if err != nil {
+		return Info{}, err
+	}
+	for _, l := range strings.Fields(string(out)) {
+		i.Layouts = append(i.Layouts, layoutName(l))
+	}
+	return i, nil
+}
+
+// layoutName strips the variant from a group name, e.g. "us(intl)".
+func layoutName(group string) string {
+	group = strings.TrimSpace(group)
+	if idx := strings.Index(group, "("); idx > 0 {
+		return group[:idx]
+	}
+	return group
+}
+
+func (x *x11) next() error {
+	return exec.Command("xkb-switch", "-n").Run()
+}
+
+func (x *x11) changes() (<-chan struct{}, error) {
+	// -W waits for group changes forever, printing each new group.
+	cmd := exec.Command("xkb-switch", "-W")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+		defer cmd.Wait()
+		s := bufio.NewScanner(out)
+		for s.Scan() {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
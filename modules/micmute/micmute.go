@@ -0,0 +1,104 @@
+// Package micmute provides an i3bar module that shows whether the default
+// PulseAudio/PipeWire source is muted, and whether anything is recording
+// from it. Clicking toggles mute.
+package micmute
+
+import (
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/pulse"
+)
+
+// Info represents the state of the default source.
+type Info struct {
+	// Source is the friendly name of the default source.
+	Source string
+	Mute   bool
+	// Recording is the number of applications recording from the source.
+	Recording int
+}
+
+// Live returns true if the microphone is unmuted and being recorded.
+func (i Info) Live() bool {
+	return !i.Mute && i.Recording > 0
+}
+
+// Module represents a microphone mute bar module.
+type Module struct {
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a microphone mute module for the default source.
+func New() *Module {
+	m := &Module{}
+	m.Output(func(i Info) bar.Output {
+		if i.Mute {
+			return outputs.Text("Mic: off")
+		}
+		return outputs.Text("Mic: on")
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Click toggles mute on the default source on left click.
+func (m *Module) Click(e bar.Event) {
+	if e.Button != bar.ButtonLeft {
+		return
+	}
+	source, err := pulse.DefaultSource()
+	if m.info.Error(err) {
+		return
+	}
+	// The new state is picked up through the server's change event.
+	m.info.Error(pulse.SetSourceMute(source.Name, !source.Mute))
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	changes := pulse.Changes()
+	m.refresh()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-changes:
+			changes = pulse.Changes()
+			m.refresh()
+			continue
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) refresh() {
+	source, err := pulse.DefaultSource()
+	if m.info.Error(err) {
+		return
+	}
+	recording, err := pulse.Recording(source)
+	if m.info.Error(err) {
+		return
+	}
+	i := Info{Source: source.Description, Mute: source.Mute, Recording: recording}
+	if old, err := m.info.Get(); err == nil && old == i {
+		return
+	}
+	m.info.Set(i)
+}
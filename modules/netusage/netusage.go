@@ -0,0 +1,239 @@
+// Package netusage provides an i3bar module that shows network throughput
+// for whichever interface currently holds the default route, so that it
+// follows the machine between ethernet, Wi-Fi and tethering.
+package netusage
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/modules/netspeed"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+)
+
+// Info represents the network activity on the default route interface.
+type Info struct {
+	// Interface is the name of the interface holding the default route,
+	// or empty if there is no default route.
+	Interface string
+	netspeed.Speeds
+	// History holds the most recent total rates, oldest first, if history
+	// was enabled using History.
+	History []unit.Datarate
+	// Metered is true if the interface is one of the configured metered
+	// interfaces, in which case MonthRx and MonthTx are also set.
+	Metered bool
+	// MonthRx and MonthTx are the data received and sent over metered
+	// interfaces during the current calendar month.
+	MonthRx, MonthTx unit.Datasize
+}
+
+// Connected returns true if there is a default route.
+func (i Info) Connected() bool {
+	return i.Interface != ""
+}
+
+// Module represents a network usage bar module.
+type Module struct {
+	base.SimpleClickHandler
+	scheduler   *timing.Scheduler
+	outputFunc  base.Value // of func(Info) bar.Output
+	historySize int
+	totals      *totals
+	metered     map[string]bool
+}
+
+// New constructs a network usage module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	m.RefreshInterval(3 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		if !i.Connected() {
+			return nil
+		}
+		return outputs.Textf("%s: %s/s", i.Interface, outputs.IByterate(i.Total()))
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// History keeps the last n total rates, e.g. for use with Sparkline.
+func (m *Module) History(n int) *Module {
+	m.historySize = n
+	return m
+}
+
+// Metered tracks monthly totals for the given interfaces, persisting them
+// to file so that they survive restarts.
+func (m *Module) Metered(file string, ifaces ...string) *Module {
+	m.totals = loadTotals(file)
+	m.metered = map[string]bool{}
+	for _, iface := range ifaces {
+		m.metered[iface] = true
+	}
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	var info Info
+	var prev counters
+	var lastRead time.Time
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	update := func() {
+		iface, err := defaultRouteInterface()
+		if err != nil || iface == "" {
+			info = Info{History: info.History}
+			return
+		}
+		cur, err := readCounters(iface)
+		now := timing.Now()
+		if err != nil {
+			info = Info{History: info.History}
+			return
+		}
+		if iface != info.Interface {
+			// Interface changed, so the previous counters are meaningless.
+			info = Info{Interface: iface, History: info.History}
+			prev, lastRead = cur, now
+			m.fillTotals(&info, now)
+			return
+		}
+		elapsed := now.Sub(lastRead).Seconds()
+		rx, tx := cur.rx-prev.rx, cur.tx-prev.tx
+		if cur.rx < prev.rx || cur.tx < prev.tx {
+			// Counters were reset, e.g. the interface was recreated.
+			rx, tx = 0, 0
+		}
+		info.Speeds = netspeed.Speeds{
+			Rx: unit.Datarate(float64(rx)/elapsed) * unit.BytePerSecond,
+			Tx: unit.Datarate(float64(tx)/elapsed) * unit.BytePerSecond,
+		}
+		if m.historySize > 0 {
+			info.History = append(info.History, info.Total())
+			if len(info.History) > m.historySize {
+				info.History = info.History[len(info.History)-m.historySize:]
+			}
+		}
+		if m.metered[iface] {
+			m.totals.add(now, unit.Datasize(rx)*unit.Byte, unit.Datasize(tx)*unit.Byte)
+		}
+		m.fillTotals(&info, now)
+		prev, lastRead = cur, now
+	}
+	update()
+	for {
+		s.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.Tick():
+			update()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+func (m *Module) fillTotals(i *Info, now time.Time) {
+	if !m.metered[i.Interface] {
+		return
+	}
+	i.Metered = true
+	i.MonthRx, i.MonthTx = m.totals.month(now)
+}
+
+// Sparkline renders rates as a string of block characters, scaled so that
+// the largest rate uses a full block.
+func Sparkline(rates []unit.Datarate) string {
+	const blocks = "▁▂▃▄▅▆▇█"
+	steps := []rune(blocks)
+	var max unit.Datarate
+	for _, r := range rates {
+		if r > max {
+			max = r
+		}
+	}
+	out := make([]rune, len(rates))
+	for idx, r := range rates {
+		step := 0
+		if max > 0 {
+			step = int(float64(r) / float64(max) * float64(len(steps)-1))
+		}
+		out[idx] = steps[step]
+	}
+	return string(out)
+}
+
+type counters struct {
+	rx, tx uint64
+}
+
+func readCounter(iface, name string) (uint64, error) {
+	b, err := ioutil.ReadFile("/sys/class/net/" + iface + "/statistics/" + name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+func readCounters(iface string) (c counters, err error) {
+	if c.rx, err = readCounter(iface, "rx_bytes"); err != nil {
+		return c, err
+	}
+	c.tx, err = readCounter(iface, "tx_bytes")
+	return c, err
+}
+
+// defaultRouteInterface returns the interface of the default route with the
+// lowest metric from /proc/net/route, or an empty string if there is none.
+func defaultRouteInterface() (string, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	const rtfUp = 0x1
+	iface := ""
+	bestMetric := uint64(0)
+	s := bufio.NewScanner(f)
+	s.Scan() // Skip the header.
+	for s.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(s.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			continue
+		}
+		if iface == "" || metric < bestMetric {
+			iface, bestMetric = fields[0], metric
+		}
+	}
+	return iface, s.Err()
+}
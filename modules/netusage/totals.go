@@ -0,0 +1,81 @@
+package netusage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/martinlindhe/unit"
+)
+
+// saveInterval limits how often totals are written to disk.
+const saveInterval = time.Minute
+
+// usage is the data sent and received in one month, in bytes.
+type usage struct {
+	Rx uint64 `json:"rx"`
+	Tx uint64 `json:"tx"`
+}
+
+// totals tracks per-month usage of metered interfaces, keyed by "2006-01".
+type totals struct {
+	sync.Mutex
+	file     string
+	months   map[string]usage
+	lastSave time.Time
+}
+
+func monthKey(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+func loadTotals(file string) *totals {
+	t := &totals{file: file, months: map[string]usage{}}
+	if b, err := ioutil.ReadFile(file); err == nil {
+		// A corrupt file just restarts the count.
+		json.Unmarshal(b, &t.months)
+	}
+	return t
+}
+
+func (t *totals) add(now time.Time, rx, tx unit.Datasize) {
+	t.Lock()
+	defer t.Unlock()
+	key := monthKey(now)
+	u := t.months[key]
+	u.Rx += uint64(rx.Bytes())
+	u.Tx += uint64(tx.Bytes())
+	t.months[key] = u
+	if now.Sub(t.lastSave) >= saveInterval {
+		t.save()
+		t.lastSave = now
+	}
+}
+
+func (t *totals) month(now time.Time) (rx, tx unit.Datasize) {
+	t.Lock()
+	defer t.Unlock()
+	u := t.months[monthKey(now)]
+	return unit.Datasize(u.Rx) * unit.Byte, unit.Datasize(u.Tx) * unit.Byte
+}
+
+// save writes the totals atomically, so that a crash while writing does not
+// lose the month so far. Errors are ignored, the totals are kept in memory
+// and will be written on the next attempt.
+func (t *totals) save() {
+	b, err := json.Marshal(t.months)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(t.file), 0755); err != nil {
+		return
+	}
+	tmp := t.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, t.file)
+}
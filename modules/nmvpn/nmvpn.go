@@ -0,0 +1,216 @@
+// Package nmvpn provides an i3bar module that shows the state of a
+// NetworkManager connection, typically a VPN or WireGuard tunnel, and can
+// toggle it over D-Bus.
+package nmvpn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/modules/vpn"
+	"github.com/soumya92/barista/outputs"
+)
+
+const (
+	nmDest         = "org.freedesktop.NetworkManager"
+	nmPath         = dbus.ObjectPath("/org/freedesktop/NetworkManager")
+	nmSettingsPath = dbus.ObjectPath("/org/freedesktop/NetworkManager/Settings")
+	activeIface    = nmDest + ".Connection.Active"
+	settingsIface  = nmDest + ".Settings"
+)
+
+// NetworkManager's NMActiveConnectionState values.
+const (
+	stateActivating   = 1
+	stateActivated    = 2
+	stateDeactivating = 3
+)
+
+// Module represents a NetworkManager connection bar module.
+type Module struct {
+	base.SimpleClickHandler
+	name       string
+	state      base.Value // of vpn.State
+	outputFunc base.Value // of func(vpn.State) bar.Output
+}
+
+// Connection constructs a module for the NetworkManager connection with the
+// given name (the "id" shown by nmcli).
+func Connection(name string) *Module {
+	m := &Module{name: name}
+	m.state.Set(vpn.Disconnected)
+	m.Output(func(s vpn.State) bar.Output {
+		switch s {
+		case vpn.Connected:
+			return outputs.Textf("%s: up", name)
+		case vpn.Waiting:
+			return outputs.Textf("%s: ...", name)
+		default:
+			return outputs.Textf("%s: down", name)
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(vpn.State) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := dbus.SystemBus()
+	if s.Error(err) {
+		return
+	}
+	// Only state changes of active connections, and connections being
+	// activated or removed, can change the state.
+	for _, rule := range []string{
+		"type='signal',sender='" + nmDest + "',interface='" + activeIface + "',member='StateChanged'",
+		"type='signal',sender='" + nmDest + "',path='" + string(nmPath) + "'," +
+			"interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'",
+	} {
+		err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule).Err
+		if s.Error(err) {
+			return
+		}
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	m.refresh(conn)
+	outputFunc := m.outputFunc.Get().(func(vpn.State) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextState := m.state.Next()
+	for {
+		s.Output(outputFunc(m.state.Get().(vpn.State)))
+		select {
+		case <-nextState:
+			nextState = m.state.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(vpn.State) bar.Output)
+		case sig := <-signals:
+			// The connection is shared, and carries signals for other
+			// modules too.
+			if relevant(sig) {
+				m.refresh(conn)
+			}
+			continue
+		}
+	}
+}
+
+// relevant returns true for signals that may change the connection state.
+func relevant(sig *dbus.Signal) bool {
+	switch sig.Name {
+	case activeIface + ".StateChanged":
+		return strings.HasPrefix(string(sig.Path), string(nmPath)+"/ActiveConnection/")
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if sig.Path != nmPath || len(sig.Body) < 2 || sig.Body[0] != nmDest {
+			return false
+		}
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		_, ok := changed["ActiveConnections"]
+		return ok
+	}
+	return false
+}
+
+// Toggle activates the connection if it is down, and deactivates it
+// otherwise. The module shows the connection as waiting until
+// NetworkManager reports the new state.
+func (m *Module) Toggle() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	active, state, err := m.find(conn)
+	if err != nil {
+		return err
+	}
+	m.state.Set(vpn.Waiting)
+	nm := conn.Object(nmDest, nmPath)
+	if active != "" && state != vpn.Disconnected {
+		err = nm.Call(nmDest+".DeactivateConnection", 0, active).Err
+	} else {
+		var settings dbus.ObjectPath
+		if settings, err = m.settings(conn); err == nil {
+			err = nm.Call(nmDest+".ActivateConnection", 0,
+				settings, dbus.ObjectPath("/"), dbus.ObjectPath("/")).Err
+		}
+	}
+	if err != nil {
+		// Restore the real state, since no change is coming.
+		m.refresh(conn)
+	}
+	return err
+}
+
+func (m *Module) refresh(conn *dbus.Conn) {
+	_, state, err := m.find(conn)
+	if err != nil {
+		state = vpn.Disconnected
+	}
+	if state != m.state.Get().(vpn.State) {
+		m.state.Set(state)
+	}
+}
+
+// find returns the active connection for the configured name, if any,
+// along with its state.
+func (m *Module) find(conn *dbus.Conn) (dbus.ObjectPath, vpn.State, error) {
+	v, err := conn.Object(nmDest, nmPath).GetProperty(nmDest + ".ActiveConnections")
+	if err != nil {
+		return "", vpn.Disconnected, err
+	}
+	paths, _ := v.Value().([]dbus.ObjectPath)
+	for _, path := range paths {
+		obj := conn.Object(nmDest, path)
+		id, err := obj.GetProperty(activeIface + ".Id")
+		if err != nil || id.Value() != m.name {
+			continue
+		}
+		st, err := obj.GetProperty(activeIface + ".State")
+		if err != nil {
+			return "", vpn.Disconnected, err
+		}
+		s, _ := st.Value().(uint32)
+		switch s {
+		case stateActivated:
+			return path, vpn.Connected, nil
+		case stateActivating, stateDeactivating:
+			return path, vpn.Waiting, nil
+		default:
+			return path, vpn.Disconnected, nil
+		}
+	}
+	return "", vpn.Disconnected, nil
+}
+
+// settings returns the settings object path for the configured name.
+func (m *Module) settings(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	var paths []dbus.ObjectPath
+	err := conn.Object(nmDest, nmSettingsPath).
+		Call(settingsIface+".ListConnections", 0).Store(&paths)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		var settings map[string]map[string]dbus.Variant
+		err := conn.Object(nmDest, path).
+			Call(settingsIface+".Connection.GetSettings", 0).Store(&settings)
+		if err != nil {
+			continue
+		}
+		if id, ok := settings["connection"]["id"]; ok && id.Value() == m.name {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no NetworkManager connection named %q", m.name)
+}
@@ -0,0 +1,138 @@
+// Package pavolume provides an i3bar module for the volume of the default
+// PulseAudio/PipeWire sink. It follows the default sink as it changes,
+// scrolling adjusts the volume and clicking toggles mute.
+package pavolume
+
+import (
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/pulse"
+)
+
+// Volume represents the state of the default sink.
+type Volume struct {
+	// Sink is the friendly name of the default sink.
+	Sink string
+	// Percent is the average volume across channels.
+	Percent int
+	Mute    bool
+}
+
+// Module represents a volume bar module.
+type Module struct {
+	step       int
+	max        int
+	volume     base.ErrorValue // of Volume
+	outputFunc base.Value      // of func(Volume) bar.Output
+}
+
+// DefaultSink constructs a volume module for the default sink.
+func DefaultSink() *Module {
+	m := &Module{step: 5, max: 100}
+	m.Output(func(v Volume) bar.Output {
+		if v.Mute {
+			return outputs.Text("Vol: MUT")
+		}
+		return outputs.Textf("Vol: %d%%", v.Percent)
+	})
+	return m
+}
+
+// Step configures the volume change per scroll step, in percent.
+func (m *Module) Step(percent int) *Module {
+	m.step = percent
+	return m
+}
+
+// Max configures the maximum volume that can be reached by scrolling,
+// in percent. Values above 100 amplify the signal.
+func (m *Module) Max(percent int) *Module {
+	m.max = percent
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Volume) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Click handles scroll and click events: scrolling changes the volume,
+// and a left click toggles mute.
+func (m *Module) Click(e bar.Event) {
+	sink, err := pulse.DefaultSink()
+	if m.volume.Error(err) {
+		return
+	}
+	switch e.Button {
+	case bar.ButtonLeft:
+		err = pulse.SetSinkMute(sink.Name, !sink.Mute)
+	case bar.ScrollUp, bar.ScrollRight:
+		err = pulse.SetSinkVolume(sink.Name, m.adjust(sink.Volume(), m.step))
+	case bar.ScrollDown, bar.ScrollLeft:
+		err = pulse.SetSinkVolume(sink.Name, m.adjust(sink.Volume(), -m.step))
+	}
+	// The new state is picked up through the server's change event.
+	m.volume.Error(err)
+}
+
+// adjust applies a step to the volume, snapping to multiples of the step
+// and clamping to the configured range. A volume already above the maximum
+// (e.g. set in pavucontrol) is left alone when scrolling up, and decreases
+// as usual when scrolling down.
+func (m *Module) adjust(volume float64, step int) float64 {
+	current := int(volume*100 + 0.5)
+	pct := current + step
+	pct -= pct % m.step
+	switch {
+	case pct < 0:
+		pct = 0
+	case step > 0 && pct > m.max:
+		pct = m.max
+		if current > m.max {
+			pct = current
+		}
+	}
+	return float64(pct) / 100
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	changes := pulse.Changes()
+	m.refresh()
+	outputFunc := m.outputFunc.Get().(func(Volume) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextVolume := m.volume.Next()
+	for {
+		if v, err := m.volume.Get(); !s.Error(err) && v != nil {
+			s.Output(outputFunc(v.(Volume)))
+		}
+		select {
+		case <-changes:
+			changes = pulse.Changes()
+			m.refresh()
+			continue
+		case <-nextVolume:
+			nextVolume = m.volume.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Volume) bar.Output)
+		}
+	}
+}
+
+func (m *Module) refresh() {
+	sink, err := pulse.DefaultSink()
+	if m.volume.Error(err) {
+		// The server may be restarting, and will send a change
+		// notification once it is back.
+		return
+	}
+	m.volume.Set(Volume{
+		Sink:    sink.Description,
+		Percent: int(sink.Volume()*100 + 0.5),
+		Mute:    sink.Mute,
+	})
+}
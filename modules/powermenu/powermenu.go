@@ -0,0 +1,157 @@
+// Package powermenu provides an i3bar module to lock the screen, log out,
+// suspend, reboot or shut down through logind. Scrolling selects an action,
+// and it only runs when clicked twice in quick succession.
+package powermenu
+
+import (
+	"time"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/logind"
+)
+
+// Actions, in scroll order.
+const (
+	Lock     = "lock"
+	Logout   = "logout"
+	Suspend  = "suspend"
+	Reboot   = "reboot"
+	Shutdown = "shutdown"
+)
+
+var actions = []string{Lock, Logout, Suspend, Reboot, Shutdown}
+
+// confirmTimeout is how long an armed action waits for the second click.
+const confirmTimeout = 3 * time.Second
+
+// Info represents the state of the power menu.
+type Info struct {
+	// Action is the selected action, e.g. "suspend".
+	Action string
+	// Armed is true after the first click, until the action is confirmed
+	// by a second click or the confirmation times out.
+	Armed bool
+}
+
+// Module represents a power menu bar module.
+type Module struct {
+	base.SimpleClickHandler
+	manager    func() (*logind.Manager, error)
+	disarm     *timing.Scheduler
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a power menu using logind on the system bus.
+func New() *Module {
+	m := &Module{manager: logind.System, disarm: timing.NewScheduler()}
+	m.info.Set(Info{Action: actions[0]})
+	m.Output(func(i Info) bar.Output {
+		if i.Armed {
+			return outputs.Textf("%s?", i.Action)
+		}
+		return outputs.Text(i.Action)
+	})
+	m.OnClick(m.click)
+	return m
+}
+
+// Manager configures the logind manager to use, e.g. a stub logind on a
+// private bus.
+func (m *Module) Manager(manager *logind.Manager) *Module {
+	m.manager = func() (*logind.Manager, error) { return manager, nil }
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+func (m *Module) click(e bar.Event) {
+	// Clicking after an error clears it, without running anything.
+	v, err := m.info.Get()
+	if err != nil {
+		m.info.Set(Info{Action: actions[0]})
+		return
+	}
+	i := v.(Info)
+	switch e.Button {
+	case bar.ScrollUp, bar.ScrollLeft:
+		m.disarm.Stop()
+		m.info.Set(Info{Action: cycle(i.Action, -1)})
+	case bar.ScrollDown, bar.ScrollRight:
+		m.disarm.Stop()
+		m.info.Set(Info{Action: cycle(i.Action, 1)})
+	case bar.ButtonLeft:
+		if !i.Armed {
+			m.disarm.After(confirmTimeout)
+			m.info.Set(Info{Action: i.Action, Armed: true})
+			return
+		}
+		m.disarm.Stop()
+		m.info.Set(Info{Action: i.Action})
+		m.info.Error(m.run(i.Action))
+	}
+}
+
+func cycle(action string, delta int) string {
+	for idx, a := range actions {
+		if a == action {
+			return actions[(idx+delta+len(actions))%len(actions)]
+		}
+	}
+	return actions[0]
+}
+
+// run performs the given action.
+func (m *Module) run(action string) error {
+	lgd, err := m.manager()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case Lock:
+		return lgd.Session().Lock()
+	case Logout:
+		return lgd.Session().Terminate()
+	case Suspend:
+		return lgd.Suspend()
+	case Reboot:
+		return lgd.Reboot()
+	case Shutdown:
+		return lgd.PowerOff()
+	}
+	return nil
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	go func() {
+		for range m.disarm.Tick() {
+			if i, err := m.info.Get(); err == nil {
+				m.info.Set(Info{Action: i.(Info).Action})
+			}
+		}
+	}()
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
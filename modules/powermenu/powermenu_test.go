@@ -0,0 +1,93 @@
+package powermenu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/logind/logindtest"
+	"github.com/aolwas/mybarista/sinktest"
+)
+
+func TestPowerMenu(t *testing.T) {
+	timing.TestMode()
+	lgd, stub := logindtest.New(t)
+	m := New().Manager(lgd)
+	next := sinktest.Stream(t, m).NextText
+	if got := next(); got != "lock" {
+		t.Errorf("initial output = %q, want lock", got)
+	}
+
+	for _, tc := range []struct {
+		button bar.Button
+		want   string
+	}{
+		{bar.ScrollDown, "logout"},
+		{bar.ScrollDown, "suspend"},
+		{bar.ScrollUp, "logout"},
+		{bar.ScrollUp, "lock"},
+		{bar.ScrollUp, "shutdown"},
+		{bar.ScrollDown, "lock"},
+		{bar.ScrollDown, "logout"},
+		{bar.ScrollDown, "suspend"},
+	} {
+		m.Click(bar.Event{Button: tc.button})
+		if got := next(); got != tc.want {
+			t.Fatalf("after scrolling: %q, want %q", got, tc.want)
+		}
+	}
+
+	m.Click(bar.Event{Button: bar.ButtonLeft})
+	if got := next(); got != "suspend?" {
+		t.Errorf("after first click: %q, want suspend?", got)
+	}
+	if calls := stub.Calls(); len(calls) != 0 {
+		t.Fatalf("first click ran %v", calls)
+	}
+
+	timing.AdvanceBy(2 * time.Second)
+	m.Click(bar.Event{Button: bar.ButtonLeft})
+	if got := next(); got != "suspend" {
+		t.Errorf("after second click: %q, want suspend", got)
+	}
+	if calls := stub.Calls(); !reflect.DeepEqual(calls, []string{"Manager.Suspend"}) {
+		t.Errorf("calls = %v, want [Manager.Suspend]", calls)
+	}
+}
+
+func TestConfirmTimeout(t *testing.T) {
+	timing.TestMode()
+	lgd, stub := logindtest.New(t)
+	m := New().Manager(lgd)
+	next := sinktest.Stream(t, m).NextText
+	next()
+
+	m.Click(bar.Event{Button: bar.ScrollDown})
+	next()
+	m.Click(bar.Event{Button: bar.ButtonLeft})
+	if got := next(); got != "logout?" {
+		t.Fatalf("after first click: %q, want logout?", got)
+	}
+	timing.AdvanceBy(confirmTimeout)
+	if got := next(); got != "logout" {
+		t.Errorf("after timeout: %q, want logout", got)
+	}
+
+	// The next click only arms the action again.
+	m.Click(bar.Event{Button: bar.ButtonLeft})
+	if got := next(); got != "logout?" {
+		t.Errorf("after click following timeout: %q, want logout?", got)
+	}
+	if calls := stub.Calls(); len(calls) != 0 {
+		t.Errorf("ran %v after the confirmation timed out", calls)
+	}
+
+	// Scrolling also disarms.
+	m.Click(bar.Event{Button: bar.ScrollDown})
+	if got := next(); got != "suspend" {
+		t.Errorf("after scrolling: %q, want suspend", got)
+	}
+}
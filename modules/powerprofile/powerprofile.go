@@ -0,0 +1,166 @@
+// Package powerprofile provides an i3bar module that shows the active
+// power-profiles-daemon profile, and switches profiles over D-Bus.
+package powerprofile
+
+import (
+	"github.com/godbus/dbus"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+)
+
+const (
+	ppDest  = "net.hadess.PowerProfiles"
+	ppPath  = dbus.ObjectPath("/net/hadess/PowerProfiles")
+	ppIface = ppDest
+)
+
+// Profiles known to power-profiles-daemon. Performance is not available
+// on every machine.
+const (
+	PowerSaver  = "power-saver"
+	Balanced    = "balanced"
+	Performance = "performance"
+)
+
+// Info represents the power profile state.
+type Info struct {
+	// Active is the active profile, e.g. "balanced".
+	Active string
+	// Profiles lists the available profiles, in the daemon's order.
+	Profiles []string
+	// Degraded is the reason performance is degraded, e.g. "lap-detected",
+	// or empty if it is not.
+	Degraded string
+}
+
+// Module represents a power profile bar module.
+type Module struct {
+	base.SimpleClickHandler
+	info       base.ErrorValue // of Info
+	outputFunc base.Value      // of func(Info) bar.Output
+}
+
+// New constructs a power profile module.
+func New() *Module {
+	m := &Module{}
+	m.Output(func(i Info) bar.Output {
+		return outputs.Text(i.Active)
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.info.Error(m.Cycle())
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	conn, err := dbus.SystemBus()
+	if s.Error(err) {
+		return
+	}
+	err = conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0,
+		"type='signal',sender='"+ppDest+"',"+
+			"interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'").Err
+	if s.Error(err) {
+		return
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	m.refresh(conn)
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextInfo := m.info.Next()
+	for {
+		if i, err := m.info.Get(); !s.Error(err) && i != nil {
+			s.Output(outputFunc(i.(Info)))
+		}
+		select {
+		case <-nextInfo:
+			nextInfo = m.info.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		case sig := <-signals:
+			if sig.Path == ppPath {
+				m.refresh(conn)
+			}
+		}
+	}
+}
+
+// Set activates the given profile. The module is updated when the daemon
+// signals the change.
+func (m *Module) Set(profile string) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	return conn.Object(ppDest, ppPath).Call("org.freedesktop.DBus.Properties.Set", 0,
+		ppIface, "ActiveProfile", dbus.MakeVariant(profile)).Err
+}
+
+// Cycle activates the next available profile.
+func (m *Module) Cycle() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return err
+	}
+	i, err := read(conn)
+	if err != nil {
+		return err
+	}
+	if len(i.Profiles) == 0 {
+		return nil
+	}
+	next := i.Profiles[0]
+	for idx, p := range i.Profiles {
+		if p == i.Active {
+			next = i.Profiles[(idx+1)%len(i.Profiles)]
+		}
+	}
+	return m.Set(next)
+}
+
+// SavePower switches to the power-saver profile. It is meant to be used as
+// a low battery action.
+func (m *Module) SavePower() error {
+	return m.Set(PowerSaver)
+}
+
+func (m *Module) refresh(conn *dbus.Conn) {
+	i, err := read(conn)
+	if m.info.Error(err) {
+		return
+	}
+	m.info.Set(i)
+}
+
+func read(conn *dbus.Conn) (Info, error) {
+	var props map[string]dbus.Variant
+	err := conn.Object(ppDest, ppPath).
+		Call("org.freedesktop.DBus.Properties.GetAll", 0, ppIface).Store(&props)
+	if err != nil {
+		return Info{}, err
+	}
+	var i Info
+	i.Active, _ = props["ActiveProfile"].Value().(string)
+	i.Degraded, _ = props["PerformanceDegraded"].Value().(string)
+	profiles, _ := props["Profiles"].Value().([]map[string]dbus.Variant)
+	for _, p := range profiles {
+		if name, ok := p["Profile"].Value().(string); ok {
+			i.Profiles = append(i.Profiles, name)
+		}
+	}
+	return i, nil
+}
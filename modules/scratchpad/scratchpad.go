@@ -0,0 +1,84 @@
+// Package scratchpad provides an i3bar module that shows the number of
+// windows parked in the i3 or sway scratchpad.
+package scratchpad
+
+import (
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// Module represents a scratchpad bar module.
+type Module struct {
+	base.SimpleClickHandler
+	outputFunc base.Value // of func(int) bar.Output
+}
+
+// New constructs a scratchpad module. Clicking it shows the next window from
+// the scratchpad, like the "scratchpad show" command.
+func New() *Module {
+	m := &Module{}
+	m.Output(func(count int) bar.Output {
+		if count == 0 {
+			return nil
+		}
+		return outputs.Textf("scratch: %d", count)
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			command("scratchpad show")
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(int) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	// Windows are moved to and from the scratchpad with window events.
+	trees, errs := i3ipc.WatchTree("window")
+	outputFunc := m.outputFunc.Get().(func(int) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	count := 0
+	for {
+		select {
+		case root, ok := <-trees:
+			if !ok {
+				s.Error(<-errs)
+				return
+			}
+			count = countScratchpad(root)
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(int) bar.Output)
+		}
+		s.Output(outputFunc(count))
+	}
+}
+
+// countScratchpad returns the number of windows in the scratchpad. Each
+// scratchpad window is wrapped in its own floating container.
+func countScratchpad(root *i3ipc.Node) int {
+	for _, ws := range root.Workspaces() {
+		if ws.Name == i3ipc.ScratchpadWorkspace {
+			return len(ws.FloatingNodes)
+		}
+	}
+	return 0
+}
+
+func command(cmd string) error {
+	conn, err := i3ipc.Connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Command(cmd)
+}
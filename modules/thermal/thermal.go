@@ -0,0 +1,183 @@
+// Package thermal provides an i3bar module that shows the CPU package
+// temperature and fan speeds from hwmon. The sensor is picked automatically
+// by driver name, falling back to the barista cputemp module's default
+// thermal zone if no known driver is found.
+package thermal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/martinlindhe/unit"
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/modules/cputemp"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+)
+
+// hwmonDir is where the kernel exposes hardware monitoring chips.
+var hwmonDir = "/sys/class/hwmon"
+
+// drivers lists hwmon drivers that report the CPU temperature,
+// in order of preference.
+var drivers = []string{"coretemp", "k10temp", "zenpower", "acpitz"}
+
+// packageLabels are the labels of the package-wide sensor of each driver.
+var packageLabels = []string{"Package id 0", "Tctl", "Tdie"}
+
+// Info represents the current temperature and fan speeds.
+type Info struct {
+	// Sensor is the name of the hwmon driver, e.g. "coretemp".
+	Sensor      string
+	Temperature unit.Temperature
+	// Max and Crit are the configured thresholds, or those reported by the
+	// sensor if not configured. They are zero if unknown.
+	Max, Crit unit.Temperature
+	// Fans are the speeds of all fans, in RPM.
+	Fans []int
+}
+
+// Module represents a thermal bar module.
+type Module struct {
+	base.SimpleClickHandler
+	scheduler  *timing.Scheduler
+	max, crit  unit.Temperature
+	outputFunc base.Value // of func(Info) bar.Output
+}
+
+// New constructs a thermal module.
+func New() *Module {
+	m := &Module{scheduler: timing.NewScheduler()}
+	m.RefreshInterval(3 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		return outputs.Textf("%.0f℃", i.Temperature.Celsius())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Thresholds overrides the thresholds reported by the sensor.
+func (m *Module) Thresholds(max, crit unit.Temperature) *Module {
+	m.max, m.crit = max, crit
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	sensor, prefix := findSensor()
+	if sensor == "" {
+		m.streamThermalZone(s)
+		return
+	}
+	info := Info{Sensor: sensor}
+	info.Max, info.Crit = m.max, m.crit
+	// Missing thresholds are left at zero, which means unknown.
+	if info.Max == 0 {
+		info.Max, _ = readTemp(prefix + "_max")
+	}
+	if info.Crit == 0 {
+		info.Crit, _ = readTemp(prefix + "_crit")
+	}
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		var err error
+		info.Temperature, err = readTemp(prefix + "_input")
+		if !s.Error(err) {
+			info.Fans = readFans()
+			s.Output(outputFunc(info))
+		}
+		select {
+		case <-m.scheduler.Tick():
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// streamThermalZone falls back to the default thermal zone, which has no
+// fans or thresholds.
+func (m *Module) streamThermalZone(s bar.Sink) {
+	cputemp.DefaultZone().Output(func(t unit.Temperature) bar.Output {
+		outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+		return outputFunc(Info{Temperature: t, Max: m.max, Crit: m.crit})
+	}).Stream(s)
+}
+
+// findSensor returns the driver name and the path prefix (without the
+// _input suffix) of the preferred CPU temperature sensor.
+func findSensor() (driver, prefix string) {
+	chips, _ := filepath.Glob(filepath.Join(hwmonDir, "hwmon*"))
+	byDriver := map[string]string{}
+	for _, chip := range chips {
+		byDriver[readString(filepath.Join(chip, "name"))] = chip
+	}
+	for _, d := range drivers {
+		chip, ok := byDriver[d]
+		if !ok {
+			continue
+		}
+		labels, _ := filepath.Glob(filepath.Join(chip, "temp*_label"))
+		for _, label := range labels {
+			l := readString(label)
+			for _, p := range packageLabels {
+				if l == p {
+					return d, strings.TrimSuffix(label, "_label")
+				}
+			}
+		}
+		return d, filepath.Join(chip, "temp1")
+	}
+	return "", ""
+}
+
+// readFans returns the speed of every fan on every hwmon chip.
+func readFans() []int {
+	inputs, _ := filepath.Glob(filepath.Join(hwmonDir, "hwmon*", "fan*_input"))
+	sort.Strings(inputs)
+	var fans []int
+	for _, input := range inputs {
+		if rpm, err := strconv.Atoi(readString(input)); err == nil {
+			fans = append(fans, rpm)
+		}
+	}
+	return fans
+}
+
+// readTemp reads a temperature in millidegrees celsius.
+func readTemp(file string) (unit.Temperature, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	milli, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, err
+	}
+	return unit.FromCelsius(float64(milli) / 1000), nil
+}
+
+func readString(file string) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
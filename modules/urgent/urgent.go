@@ -0,0 +1,104 @@
+// Package urgent provides an i3bar module that lists the i3 or sway
+// workspaces with the urgent hint, for when the workspace buttons are on
+// another monitor.
+package urgent
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// Module represents an urgent workspaces bar module.
+type Module struct {
+	base.SimpleClickHandler
+	urgent     base.ErrorValue // of []string
+	outputFunc base.Value      // of func([]string) bar.Output
+}
+
+// New constructs an urgent workspaces module. It is hidden when no
+// workspace is urgent, and clicking it focuses the first urgent workspace.
+func New() *Module {
+	m := &Module{}
+	m.urgent.Set([]string(nil))
+	m.Output(func(names []string) bar.Output {
+		return outputs.Text(strings.Join(names, " ")).Urgent(true)
+	})
+	m.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			m.FocusFirst()
+		}
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined
+// function. It is only called when at least one workspace is urgent.
+func (m *Module) Output(outputFunc func([]string) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// FocusFirst switches to the first urgent workspace, if any.
+func (m *Module) FocusFirst() {
+	v, err := m.urgent.Get()
+	if err != nil || len(v.([]string)) == 0 {
+		return
+	}
+	conn, err := i3ipc.Connect()
+	if m.urgent.Error(err) {
+		return
+	}
+	defer conn.Close()
+	// Names may contain spaces and quotes.
+	name := v.([]string)[0]
+	m.urgent.Error(conn.Command("workspace " + strconv.Quote(name)))
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	trees, errs := i3ipc.WatchTree("workspace", "window")
+	outputFunc := m.outputFunc.Get().(func([]string) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextUrgent := m.urgent.Next()
+	for {
+		select {
+		case root, ok := <-trees:
+			if !ok {
+				s.Error(<-errs)
+				return
+			}
+			m.urgent.Set(urgentWorkspaces(root))
+			continue
+		case <-nextUrgent:
+			nextUrgent = m.urgent.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func([]string) bar.Output)
+		}
+		v, err := m.urgent.Get()
+		switch {
+		case s.Error(err):
+		case len(v.([]string)) == 0:
+			s.Output(nil)
+		default:
+			s.Output(outputFunc(v.([]string)))
+		}
+	}
+}
+
+// urgentWorkspaces returns the names of urgent workspaces, in tree order.
+func urgentWorkspaces(root *i3ipc.Node) []string {
+	var names []string
+	for _, ws := range root.Workspaces() {
+		if ws.Urgent && ws.Name != i3ipc.ScratchpadWorkspace {
+			names = append(names, ws.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,180 @@
+// Package wifi provides an i3bar module that extends the barista wlan module
+// with signal strength, read from /proc/net/wireless on a timer instead of
+// shelling out on every refresh.
+package wifi
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/modules/wlan"
+	"github.com/soumya92/barista/outputs"
+	"github.com/soumya92/barista/timing"
+)
+
+// The link quality is derived from the signal level, since the scale of
+// the quality reported by drivers varies (e.g. out of 70 for iwlwifi, out
+// of 100 for others). Levels at or below minLevel have no quality, levels
+// at or above maxLevel are perfect.
+const (
+	minLevel = -90
+	maxLevel = -30
+)
+
+// Signal represents the signal strength of a wireless link.
+type Signal struct {
+	// Quality is the link quality derived from Level, from 0 to 1.
+	Quality float64
+	// Level is the signal level, in dBm.
+	Level int
+}
+
+// Percent returns the link quality as a percentage.
+func (s Signal) Percent() int {
+	return int(s.Quality * 100)
+}
+
+// Bars returns the link quality as a number of bars, from 0 to 4.
+func (s Signal) Bars() int {
+	return int(s.Quality*4 + 0.5)
+}
+
+// Info represents the wireless connection along with its signal strength.
+type Info struct {
+	wlan.Info
+	Signal Signal
+}
+
+// Band returns the frequency band of the connection, e.g. "2.4GHz" or "5GHz".
+func (i Info) Band() string {
+	switch ghz := i.Frequency.Gigahertz(); {
+	case ghz <= 0:
+		return ""
+	case ghz < 3:
+		return "2.4GHz"
+	case ghz < 5.9:
+		return "5GHz"
+	default:
+		return "6GHz"
+	}
+}
+
+// Module represents a wifi bar module.
+type Module struct {
+	base.SimpleClickHandler
+	wlan       *wlan.Module
+	wlanInfo   base.Value // of wlan.Info
+	scheduler  *timing.Scheduler
+	outputFunc base.Value // of func(Info) bar.Output
+}
+
+// Named constructs a wifi module for the given interface.
+func Named(iface string) *Module {
+	return newModule(wlan.Named(iface))
+}
+
+// Any constructs a wifi module for the first available wireless interface.
+func Any() *Module {
+	return newModule(wlan.Any())
+}
+
+func newModule(w *wlan.Module) *Module {
+	m := &Module{wlan: w, scheduler: timing.NewScheduler()}
+	m.wlanInfo.Set(wlan.Info{})
+	// The wlan module only reports connection changes, its output is not
+	// displayed directly but combined with the signal strength.
+	w.Output(func(i wlan.Info) bar.Output {
+		m.wlanInfo.Set(i)
+		return nil
+	})
+	m.RefreshInterval(5 * time.Second)
+	m.Output(func(i Info) bar.Output {
+		if !i.Connected() {
+			return nil
+		}
+		return outputs.Textf("%s (%d%%)", i.SSID, i.Signal.Percent())
+	})
+	return m
+}
+
+// Output configures a module to display the output of a user-defined function.
+func (m *Module) Output(outputFunc func(Info) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// RefreshInterval configures the polling frequency for signal strength.
+// Connection changes are always reported immediately.
+func (m *Module) RefreshInterval(interval time.Duration) *Module {
+	m.scheduler.Every(interval)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	go m.wlan.Stream(bar.Sink(func(bar.Segments) {}))
+	outputFunc := m.outputFunc.Get().(func(Info) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	nextWlanInfo := m.wlanInfo.Next()
+	for {
+		info := Info{Info: m.wlanInfo.Get().(wlan.Info)}
+		if info.Connected() {
+			// Errors are not fatal here, the connection details are still
+			// useful without the signal strength.
+			info.Signal, _ = readSignal(info.Name)
+		}
+		s.Output(outputFunc(info))
+		select {
+		case <-m.scheduler.Tick():
+		case <-nextWlanInfo:
+			nextWlanInfo = m.wlanInfo.Next()
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(Info) bar.Output)
+		}
+	}
+}
+
+// readSignal reads the signal strength for an interface from
+// /proc/net/wireless, which looks like:
+//
+//	Inter-| sta-|   Quality        |   Discarded packets
+//	 face | tus | link level noise |  nwid  crypt ...
+//	 wlan0: 0000   54.  -56.  -256        0      0 ...
+func readSignal(iface string) (Signal, error) {
+	f, err := os.Open("/proc/net/wireless")
+	if err != nil {
+		return Signal{}, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 || fields[0] != iface+":" {
+			continue
+		}
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[3], "."), 64)
+		if err != nil {
+			return Signal{}, err
+		}
+		return Signal{Quality: quality(level), Level: int(level)}, nil
+	}
+	return Signal{}, s.Err()
+}
+
+// quality maps a signal level in dBm linearly to a quality from 0 to 1.
+func quality(level float64) float64 {
+	q := (level - minLevel) / (maxLevel - minLevel)
+	switch {
+	case q < 0:
+		return 0
+	case q > 1:
+		return 1
+	}
+	return q
+}
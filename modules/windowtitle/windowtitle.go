@@ -0,0 +1,131 @@
+// Package windowtitle provides an i3bar module that shows the title of the
+// focused window under i3 or sway.
+package windowtitle
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/soumya92/barista/bar"
+	"github.com/soumya92/barista/base"
+	"github.com/soumya92/barista/outputs"
+
+	"github.com/aolwas/mybarista/i3ipc"
+)
+
+// Module represents a focused window title bar module.
+type Module struct {
+	width      int
+	outputFunc base.Value // of func(string) bar.Output
+}
+
+// New constructs a focused window title module.
+func New() *Module {
+	m := &Module{width: 50}
+	m.Output(func(title string) bar.Output {
+		return outputs.Text(title)
+	})
+	return m
+}
+
+// Width configures the maximum width of the title, in characters. Longer
+// titles are truncated with an ellipsis.
+func (m *Module) Width(width int) *Module {
+	m.width = width
+	return m
+}
+
+// Output configures a module to display the output of a user-defined
+// function. It is not called when no window is focused, e.g. on an empty
+// workspace, and the module is hidden instead.
+func (m *Module) Output(outputFunc func(string) bar.Output) *Module {
+	m.outputFunc.Set(outputFunc)
+	return m
+}
+
+// Stream starts the module.
+func (m *Module) Stream(s bar.Sink) {
+	// Requests cannot be sent on a subscribed connection, so the tree is
+	// read on a separate one.
+	query, err := i3ipc.Connect()
+	if s.Error(err) {
+		return
+	}
+	defer query.Close()
+	conn, err := i3ipc.Connect()
+	if s.Error(err) {
+		return
+	}
+	defer conn.Close()
+	events, err := conn.Subscribe("window", "workspace")
+	if s.Error(err) {
+		return
+	}
+	title, err := focusedTitle(query)
+	if s.Error(err) {
+		return
+	}
+	outputFunc := m.outputFunc.Get().(func(string) bar.Output)
+	nextOutputFunc := m.outputFunc.Next()
+	for {
+		if title == "" {
+			s.Output(nil)
+		} else {
+			s.Output(outputFunc(truncate(title, m.width)))
+		}
+		select {
+		case e, ok := <-events:
+			if !ok {
+				s.Error(errors.New("lost i3 IPC connection"))
+				return
+			}
+			var payload struct {
+				Change    string      `json:"change"`
+				Container *i3ipc.Node `json:"container"`
+			}
+			if json.Unmarshal(e.Payload, &payload) != nil {
+				continue
+			}
+			if e.Type == i3ipc.WindowEvent && payload.Container != nil &&
+				(payload.Change == "focus" || payload.Change == "title") {
+				if payload.Container.Focused {
+					title = payload.Container.Name
+				}
+				continue
+			}
+			// Closing a window or switching workspace can leave nothing,
+			// or a window we have no event for, focused.
+			if title, err = focusedTitle(query); s.Error(err) {
+				return
+			}
+		case <-nextOutputFunc:
+			nextOutputFunc = m.outputFunc.Next()
+			outputFunc = m.outputFunc.Get().(func(string) bar.Output)
+		}
+	}
+}
+
+// focusedTitle returns the title of the focused window, or an empty string
+// if the focus is on a workspace.
+func focusedTitle(conn *i3ipc.Conn) (string, error) {
+	root, err := conn.Tree()
+	if err != nil {
+		return "", err
+	}
+	focused := root.Find(func(n *i3ipc.Node) bool { return n.Focused })
+	if focused == nil || focused.Type == "workspace" {
+		return "", nil
+	}
+	return focused.Name, nil
+}
+
+func truncate(title string, width int) string {
+	r := []rune(title)
+	if width <= 0 || len(r) <= width {
+		return title
+	}
+	if width == 1 {
+		return "⋯"
+	}
+	return string(r[:width-1]) + "⋯"
+}
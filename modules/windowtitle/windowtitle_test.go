@@ -0,0 +1,58 @@
+package windowtitle
+
+import (
+	"testing"
+
+	"github.com/aolwas/mybarista/i3ipc"
+	"github.com/aolwas/mybarista/i3ipc/i3ipctest"
+	"github.com/aolwas/mybarista/sinktest"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		title string
+		width int
+		want  string
+	}{
+		{"vim", 10, "vim"},
+		{"0123456789", 10, "0123456789"},
+		{"0123456789a", 10, "012345678⋯"},
+		{"élève — café", 6, "élève⋯"},
+		{"anything", 1, "⋯"},
+		{"unlimited", 0, "unlimited"},
+	} {
+		if got := truncate(tc.title, tc.width); got != tc.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tc.title, tc.width, got, tc.want)
+		}
+	}
+}
+
+const tree = `{"type":"root","nodes":[{"type":"output","nodes":[
+	{"type":"workspace","name":"1","focused":false,"nodes":[
+		{"type":"con","name":"a window with a long title","focused":true}
+	]}
+]}]}`
+
+const emptyTree = `{"type":"root","nodes":[{"type":"output","nodes":[
+	{"type":"workspace","name":"2","focused":true}
+]}]}`
+
+func TestWindowTitle(t *testing.T) {
+	srv := i3ipctest.New(t)
+	srv.Reply(i3ipc.GetTree, tree)
+	next := sinktest.Stream(t, New().Width(10)).NextText
+
+	<-srv.Subscribed()
+	if got := next(); got != "a window ⋯" {
+		t.Errorf("initial title %q, want truncated to 10 characters", got)
+	}
+	srv.Event(i3ipc.WindowEvent, `{"change":"title","container":{"name":"short","focused":true}}`)
+	if got := next(); got != "short" {
+		t.Errorf("title %q after title change, want short", got)
+	}
+	srv.Reply(i3ipc.GetTree, emptyTree)
+	srv.Event(i3ipc.WorkspaceEvent, `{"change":"focus"}`)
+	if got := next(); got != "" {
+		t.Errorf("title %q on an empty workspace, want hidden", got)
+	}
+}
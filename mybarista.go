@@ -2,28 +2,71 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/soumya92/barista"
 	"github.com/soumya92/barista/bar"
 	"github.com/soumya92/barista/colors"
-	"github.com/soumya92/barista/modules/battery"
 	"github.com/soumya92/barista/modules/clock"
 	"github.com/soumya92/barista/modules/group"
 	"github.com/soumya92/barista/modules/media"
 	"github.com/soumya92/barista/modules/meminfo"
 	"github.com/soumya92/barista/modules/sysinfo"
+	"github.com/soumya92/barista/modules/vpn"
 	"github.com/soumya92/barista/modules/weather"
 	"github.com/soumya92/barista/modules/weather/openweathermap"
 	"github.com/soumya92/barista/outputs"
 	"github.com/soumya92/barista/pango"
+	"github.com/soumya92/barista/timing"
+
+	"github.com/aolwas/mybarista/logind"
+	"github.com/aolwas/mybarista/modules/audiodevice"
+	"github.com/aolwas/mybarista/modules/backlight"
+	"github.com/aolwas/mybarista/modules/batteries"
+	"github.com/aolwas/mybarista/modules/bindingmode"
+	"github.com/aolwas/mybarista/modules/bluetooth"
+	"github.com/aolwas/mybarista/modules/cpufreq"
+	"github.com/aolwas/mybarista/modules/diskstats"
+	"github.com/aolwas/mybarista/modules/dnd"
+	"github.com/aolwas/mybarista/modules/inhibit"
+	"github.com/aolwas/mybarista/modules/kbdlayout"
+	"github.com/aolwas/mybarista/modules/micmute"
+	"github.com/aolwas/mybarista/modules/netusage"
+	"github.com/aolwas/mybarista/modules/nmvpn"
+	"github.com/aolwas/mybarista/modules/pavolume"
+	"github.com/aolwas/mybarista/modules/powermenu"
+	"github.com/aolwas/mybarista/modules/powerprofile"
+	"github.com/aolwas/mybarista/modules/scratchpad"
+	"github.com/aolwas/mybarista/modules/thermal"
+	"github.com/aolwas/mybarista/modules/urgent"
+	"github.com/aolwas/mybarista/modules/wifi"
+	"github.com/aolwas/mybarista/modules/windowtitle"
+	"github.com/aolwas/mybarista/notify"
+	"github.com/aolwas/mybarista/powersave"
 )
 
 var spacer = pango.Text(" ").XXSmall()
 
+// networkManager is launched when clicking on the wifi block.
+var networkManager = []string{"nm-connection-editor"}
+
+// governorHelper sets the CPU governor given as its last argument.
+var governorHelper = []string{"sudo", "-n", "cpupower", "frequency-set", "-g"}
+
+// thresholdHelper sets charge thresholds, it is given the battery name and
+// the start and end thresholds as its last arguments.
+var thresholdHelper = []string{"sudo", "-n", "/usr/local/bin/battery-thresholds"}
+
+// vpnConnection is the NetworkManager connection toggled by the vpn block.
+const vpnConnection = "corporate"
+
 func truncate(in string, l int) string {
 	if len([]rune(in)) <= l {
 		return in
@@ -46,6 +89,24 @@ func formatMediaTime(d time.Duration) string {
 	return fmt.Sprintf("%d:%02d", m, s)
 }
 
+// formatDuration formats a battery time estimate, e.g. "1h05".
+func formatDuration(d time.Duration) string {
+	h, m, _ := hms(d)
+	return fmt.Sprintf("%dh%02d", h, m)
+}
+
+// clockOutput shows the date and the time in the given layout.
+func clockOutput(layout string) func(time.Time) bar.Output {
+	return func(now time.Time) bar.Output {
+		return outputs.Pango(
+			pango.Text(" "),
+			now.Format("Jan 2 "),
+			pango.Text(" "),
+			now.Format(layout),
+		)
+	}
+}
+
 func mediaFormatFunc(m media.Info) bar.Output {
 	if m.PlaybackStatus == media.Stopped || m.PlaybackStatus == media.Disconnected {
 		return nil
@@ -72,6 +133,91 @@ func startTaskManager(e bar.Event) {
 	}
 }
 
+// launch starts a command without waiting for it, so that long-lived UIs
+// do not block the click handler.
+func launch(cmd ...string) {
+	c := exec.Command(cmd[0], cmd[1:]...)
+	if c.Start() == nil {
+		go c.Wait()
+	}
+}
+
+// batteryDetail shows the health, cycle count and charge thresholds of each
+// system battery.
+func batteryDetail(b batteries.Info) bar.Output {
+	parts := []interface{}{pango.Text("")}
+	for _, bat := range b.Batteries {
+		if bat.Peripheral {
+			continue
+		}
+		parts = append(parts, spacer, pango.Textf("%s %.0f%% health", bat.Name, bat.Health()*100))
+		if bat.CycleCount > 0 {
+			parts = append(parts, pango.Textf(", %d cycles", bat.CycleCount))
+		}
+		if bat.Conserving() {
+			parts = append(parts, pango.Textf(", charging %d-%d%%", bat.ChargeStart, bat.ChargeEnd).XSmall())
+		}
+	}
+	return outputs.Pango(parts...)
+}
+
+// notifyAction returns a battery action that shows a desktop notification.
+func notifyAction(summary, body string, urgency notify.Urgency) func() error {
+	return func() error {
+		_, err := notify.Send(summary, body, urgency)
+		return err
+	}
+}
+
+// logindAction returns an action that calls logind on the system bus.
+func logindAction(action func(*logind.Manager) error) func() error {
+	return func() error {
+		m, err := logind.System()
+		if err != nil {
+			return err
+		}
+		return action(m)
+	}
+}
+
+// pauseWhileLocked pauses the bar while the session is locked, so that
+// nothing polls or hits the weather API behind the lock screen. Schedulers
+// that would have fired while paused fire as soon as the session unlocks.
+// The battery block and the power saving policy keep running, since their
+// timers are not paused.
+func pauseWhileLocked() {
+	m, err := logind.System()
+	if err != nil {
+		// Without logind the bar keeps running, just never paused.
+		return
+	}
+	locked, err := m.Session().WatchLock()
+	if err != nil {
+		return
+	}
+	go func() {
+		for l := range locked {
+			if l {
+				timing.Pause()
+			} else {
+				timing.Resume()
+			}
+		}
+	}()
+}
+
+// releaseOnExit releases the idle inhibitor when i3bar stops the bar, rather
+// than leaving it to the kernel to drop the lock when the process exits.
+func releaseOnExit(awake *inhibit.Module) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	go func() {
+		<-stop
+		awake.Release()
+		os.Exit(0)
+	}()
+}
+
 func home(path string) string {
 	usr, err := user.Current()
 	if err != nil {
@@ -89,15 +235,7 @@ func main() {
 		"dim-icon": "#777",
 	})
 
-	localtime := clock.Local().
-		Output(time.Second, func(now time.Time) bar.Output {
-			return outputs.Pango(
-				pango.Text(" "),
-				now.Format("Jan 2 "),
-				pango.Text(" "),
-				now.Format("15:04:05"),
-			)
-		})
+	localtime := clock.Local().Output(time.Second, clockOutput("15:04:05"))
 	localtime.OnClick(func(e bar.Event) {
 		if e.Button == bar.ButtonLeft {
 			exec.Command("gsimplecal").Run()
@@ -189,40 +327,411 @@ func main() {
 	})
 	freeMem.OnClick(startTaskManager)
 
-	batt := battery.Named("BAT0").Output(func(b battery.Info) bar.Output {
-		var pstate *pango.Node
-		if b.PluggedIn() {
-			pstate = pango.Text(" ")
-		} else {
-			pstate = pango.Text("")
+	temp := thermal.New().Output(func(i thermal.Info) bar.Output {
+		parts := []interface{}{pango.Textf(" %.0f℃", i.Temperature.Celsius())}
+		for _, rpm := range i.Fans {
+			parts = append(parts, spacer, pango.Textf("%d rpm", rpm).XSmall())
 		}
-		out := outputs.Pango(pstate, pango.Textf("%d%%", b.RemainingPct()))
+		out := outputs.Pango(parts...)
 		switch {
-		case b.PluggedIn():
-			out = out
-		case b.RemainingTime() < time.Duration(5)*time.Minute:
+		case i.Crit > 0 && i.Temperature >= i.Crit:
 			out.Urgent(true)
-		case b.RemainingTime() < time.Duration(10)*time.Minute:
+		case i.Max > 0 && i.Temperature >= i.Max:
 			out.Color(colors.Scheme("bad"))
-		case b.RemainingTime() < time.Duration(30)*time.Minute:
+		case i.Max > 0 && i.Temperature >= i.Max-10:
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	temp.OnClick(startTaskManager)
+
+	freq := cpufreq.New().
+		Governors("powersave", "performance").
+		Helper(governorHelper...).
+		Output(func(i cpufreq.Info) bar.Output {
+			profile := i.Governor
+			if i.EPP != "" {
+				profile = i.EPP
+			}
+			out := outputs.Pango(
+				pango.Textf(" %.1fGHz", i.Frequency.Gigahertz()), spacer,
+				pango.Text(profile).XSmall(),
+			)
+			if i.Throttled {
+				out.Color(colors.Scheme("degraded"))
+			}
+			return out
+		})
+
+	// Shows the busiest disk, to tell at a glance whether builds are IO-bound.
+	diskIO := diskstats.Busiest().Output(func(i diskstats.Info) bar.Output {
+		out := outputs.Pango(
+			pango.Text(" "), i.Disk, spacer,
+			pango.Text("").XSmall(), outputs.IByterate(i.Input), spacer,
+			pango.Text("").XSmall(), outputs.IByterate(i.Output),
+		)
+		switch {
+		case i.Utilisation > 0.9:
+			out.Color(colors.Scheme("bad"))
+		case i.Utilisation > 0.6:
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	diskIO.OnClick(startTaskManager)
+
+	// Follows the default route, and keeps monthly totals for tethering.
+	net := netusage.New().
+		History(10).
+		Metered(home(".local/share/mybarista/netusage.json"), "usb0", "wwan0").
+		Output(func(i netusage.Info) bar.Output {
+			if !i.Connected() {
+				return outputs.Text(" offline").Color(colors.Scheme("bad"))
+			}
+			parts := []interface{}{
+				pango.Text(" "), i.Interface, spacer,
+				pango.Text(netusage.Sparkline(i.History)).Color(colors.Scheme("dim-icon")), spacer,
+				pango.Text("").XSmall(), outputs.IByterate(i.Rx), spacer,
+				pango.Text("").XSmall(), outputs.IByterate(i.Tx),
+			}
+			if i.Metered {
+				parts = append(parts, spacer, pango.Textf("(%s this month)",
+					outputs.IBytesize(i.MonthRx+i.MonthTx)).XSmall())
+			}
+			return outputs.Pango(parts...)
+		})
+
+	wlan := wifi.Any().Output(func(i wifi.Info) bar.Output {
+		if !i.Connected() {
+			return outputs.Pango(pango.Text(" "), "disconnected").
+				Color(colors.Scheme("bad"))
+		}
+		out := outputs.Pango(
+			pango.Text(" "), truncate(i.SSID, 20), spacer,
+			pango.Text(strings.Repeat("▮", i.Signal.Bars())+strings.Repeat("▯", 4-i.Signal.Bars())), spacer,
+			pango.Text(i.Band()).XSmall(),
+		)
+		if i.Signal.Bars() <= 1 {
+			out.Color(colors.Scheme("degraded"))
+		}
+		return out
+	})
+	wlan.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			launch(networkManager...)
+		}
+	})
+
+	bt := bluetooth.New().Output(func(i bluetooth.Info) bar.Output {
+		switch {
+		case !i.Available:
+			return nil
+		case !i.Powered:
+			return outputs.Pango(pango.Text("").Color(colors.Scheme("dim-icon")))
+		}
+		parts := []interface{}{pango.Text("")}
+		if d, ok := i.Selection(); ok {
+			state := "connect"
+			if d.Connected {
+				state = "disconnect"
+			}
+			parts = append(parts, spacer, d.Name, spacer, pango.Textf("(middle click to %s)", state).XSmall())
+			return outputs.Pango(parts...)
+		}
+		for _, d := range i.Connected() {
+			parts = append(parts, spacer, d.Name)
+			if d.Battery >= 0 {
+				parts = append(parts, spacer, pango.Textf("%d%%", d.Battery).XSmall())
+			}
+		}
+		return outputs.Pango(parts...)
+	})
+
+	corpVPN := nmvpn.Connection(vpnConnection).Output(func(s vpn.State) bar.Output {
+		switch s {
+		case vpn.Connected:
+			return outputs.Pango(pango.Text(" "), "VPN").Color(colors.Scheme("good"))
+		case vpn.Waiting:
+			return outputs.Pango(pango.Text(" "), "VPN").Color(colors.Scheme("degraded"))
+		default:
+			return outputs.Pango(pango.Text(" "), "VPN").Color(colors.Scheme("dim-icon"))
+		}
+	})
+	corpVPN.OnClick(func(e bar.Event) {
+		if e.Button == bar.ButtonLeft {
+			if err := corpVPN.Toggle(); err != nil {
+				notify.Send("VPN", err.Error(), notify.Normal)
+			}
+		}
+	})
+
+	// Keeps the screen on for presentations and long builds.
+	awake := inhibit.New().AutoRelease(2 * time.Hour).Output(func(i inhibit.Info) bar.Output {
+		if !i.Active {
+			return outputs.Pango(pango.Text("").Color(colors.Scheme("dim-icon")))
+		}
+		return outputs.Pango(
+			pango.Text(""), spacer,
+			pango.Text(formatDuration(i.Remaining)).XSmall(),
+		).Color(colors.Scheme("degraded"))
+	})
+
+	profile := powerprofile.New().Output(func(i powerprofile.Info) bar.Output {
+		icon := ""
+		switch i.Active {
+		case powerprofile.PowerSaver:
+			icon = ""
+		case powerprofile.Performance:
+			icon = ""
+		}
+		out := outputs.Pango(pango.Text(icon), spacer, pango.Text(i.Active).XSmall())
+		if i.Degraded != "" {
 			out.Color(colors.Scheme("degraded"))
-		case b.RemainingTime() > time.Duration(45)*time.Minute:
-			out.Color(colors.Scheme("good"))
 		}
 		return out
 	})
 
+	// Not slowed down on battery: the interval must stay well below the
+	// shortest action window, since uevents do not report every change.
+	batt := batteries.All().
+		RefreshInterval(time.Minute).
+		Smoothing(home(".local/share/mybarista/discharge.json"), 10*time.Minute).
+		On(
+			batteries.Action{BelowPct: 20, Do: profile.SavePower},
+			batteries.Action{
+				Below: 15 * time.Minute,
+				Do:    notifyAction("Battery low", "15 minutes remaining", notify.Normal),
+			},
+			batteries.Action{
+				Below: 5 * time.Minute,
+				Do:    notifyAction("Battery critical", "Plug in now, hibernating soon", notify.Critical),
+			},
+			batteries.Action{
+				Below:    2 * time.Minute,
+				BelowPct: 3,
+				Name:     "hibernate",
+				Grace:    time.Minute,
+				Do:       logindAction((*logind.Manager).Hibernate),
+			},
+		).
+		ThresholdHelper(thresholdHelper...).
+		Output(func(b batteries.Info) bar.Output {
+			if !b.Known() {
+				// No battery, e.g. on a desktop.
+				return nil
+			}
+			if b.Detail {
+				return batteryDetail(b)
+			}
+			if b.Pending != nil {
+				return outputs.Pango(
+					pango.Text(" "),
+					pango.Textf("%s in %s, plug in to cancel", b.Pending.Name, formatMediaTime(b.Pending.Remaining)),
+				).Urgent(true)
+			}
+			var pstate *pango.Node
+			if b.PluggedIn() {
+				pstate = pango.Text(" ")
+			} else {
+				pstate = pango.Text("")
+			}
+			parts := []interface{}{pstate, pango.Textf("%d%%", b.RemainingPct())}
+			system := 0
+			for _, bat := range b.Batteries {
+				if !bat.Peripheral {
+					system++
+				}
+			}
+			if system > 1 {
+				for _, bat := range b.Batteries {
+					if !bat.Peripheral {
+						parts = append(parts, spacer, pango.Textf("%s:%d%%", bat.Name, bat.Capacity).XSmall())
+					}
+				}
+			}
+			switch {
+			case b.Charging() && b.TimeToFull() > 0:
+				parts = append(parts, spacer, pango.Textf("(%s to full)", formatDuration(b.TimeToFull())).XSmall())
+			case !b.PluggedIn() && b.SmoothedRemainingTime() > 0:
+				parts = append(parts, spacer, pango.Textf("(%s)", formatDuration(b.SmoothedRemainingTime())).XSmall())
+			}
+			if b.Power > 0 {
+				parts = append(parts, spacer, pango.Textf("%.1fW", b.Power).XSmall())
+			}
+			out := outputs.Pango(parts...)
+			switch {
+			case b.PluggedIn():
+				if b.RemainingPct() >= 99 {
+					out.Color(colors.Scheme("good"))
+				}
+			case b.SmoothedRemainingTime() <= 0:
+				// Unknown, e.g. right after unplugging.
+			case b.SmoothedRemainingTime() < time.Duration(5)*time.Minute:
+				out.Urgent(true)
+			case b.SmoothedRemainingTime() < time.Duration(10)*time.Minute:
+				out.Color(colors.Scheme("bad"))
+			case b.SmoothedRemainingTime() < time.Duration(30)*time.Minute:
+				out.Color(colors.Scheme("degraded"))
+			case b.SmoothedRemainingTime() > time.Duration(45)*time.Minute:
+				out.Color(colors.Scheme("good"))
+			}
+			return out
+		})
+
+	// Right click shows battery health, middle click toggles charging to 80%.
+	batt.OnClick(func(e bar.Event) {
+		switch e.Button {
+		case bar.ButtonRight:
+			batt.ToggleDetail()
+		case bar.ButtonMiddle:
+			if err := batt.ToggleConservation(75, 80); err != nil {
+				notify.Send("Battery thresholds", err.Error(), notify.Normal)
+			}
+		}
+	})
+
+	bright := backlight.Default().Output(func(i backlight.Info) bar.Output {
+		return outputs.Pango(pango.Text(" "), pango.Textf("%d%%", i.Percent()))
+	})
+
+	vol := pavolume.DefaultSink().Step(5).Output(func(v pavolume.Volume) bar.Output {
+		switch {
+		case v.Mute:
+			return outputs.Pango(pango.Text(" "), "muted").Color(colors.Scheme("dim-icon"))
+		case v.Percent == 0:
+			return outputs.Pango(pango.Text(" "), pango.Textf("%d%%", v.Percent))
+		case v.Percent < 50:
+			return outputs.Pango(pango.Text(" "), pango.Textf("%d%%", v.Percent))
+		case v.Percent <= 100:
+			return outputs.Pango(pango.Text(" "), pango.Textf("%d%%", v.Percent))
+		default:
+			return outputs.Pango(pango.Text(" "), pango.Textf("%d%%", v.Percent)).
+				Color(colors.Scheme("degraded"))
+		}
+	})
+
+	// Left click cycles outputs, right click cycles microphones.
+	audioDev := audiodevice.New().Output(func(i audiodevice.Info) bar.Output {
+		return outputs.Pango(
+			pango.Text(" "), truncate(i.Sink, 20), spacer,
+			pango.Text(" ").Color(colors.Scheme("dim-icon")),
+			pango.Text(truncate(i.Source, 12)).XSmall(),
+		)
+	})
+
+	mic := micmute.New().Output(func(i micmute.Info) bar.Output {
+		switch {
+		case i.Mute:
+			return outputs.Pango(pango.Text("").Color(colors.Scheme("dim-icon")))
+		case i.Live():
+			return outputs.Pango(pango.Text(" "), "live").Color(colors.Scheme("bad"))
+		default:
+			return outputs.Pango(pango.Text(""))
+		}
+	})
+
+	mode := bindingmode.New().Output(func(mode string) bar.Output {
+		return outputs.Text(mode).Urgent(true)
+	})
+
+	title := windowtitle.New().Width(60).Output(func(title string) bar.Output {
+		return outputs.Pango(pango.Text(title).Color(colors.Scheme("dim-icon")))
+	})
+
+	scratch := scratchpad.New().Output(func(count int) bar.Output {
+		if count == 0 {
+			return nil
+		}
+		return outputs.Pango(pango.Text(" "), pango.Textf("%d", count))
+	})
+
+	urgentWs := urgent.New().Output(func(names []string) bar.Output {
+		return outputs.Pango(pango.Text(" "), strings.Join(names, " ")).Urgent(true)
+	})
+
 	gmplay := media.New("google-play-music-desktop-player").Output(mediaFormatFunc)
 
+	kbd := kbdlayout.New().Output(func(i kbdlayout.Info) bar.Output {
+		return outputs.Pango(pango.Text(" "), strings.ToUpper(i.Layout))
+	})
+
+	// On battery, poll everything less often and stop redrawing the clock
+	// every second.
+	saver := powersave.New(4)
+	saver.Interval(3*time.Second, func(d time.Duration) { loadAvg.RefreshInterval(d) })
+	saver.Interval(3*time.Second, func(d time.Duration) { freeMem.RefreshInterval(d) })
+	saver.Interval(3*time.Second, func(d time.Duration) { diskIO.RefreshInterval(d) })
+	saver.Interval(3*time.Second, func(d time.Duration) { temp.RefreshInterval(d) })
+	saver.Interval(3*time.Second, func(d time.Duration) { freq.RefreshInterval(d) })
+	saver.Interval(3*time.Second, func(d time.Duration) { net.RefreshInterval(d) })
+	saver.Interval(5*time.Second, func(d time.Duration) { wlan.RefreshInterval(d) })
+	saver.Interval(10*time.Minute, func(d time.Duration) { wthr.RefreshInterval(d) })
+	saver.OnChange(func(onBattery bool) {
+		if onBattery {
+			localtime.Output(time.Minute, clockOutput("15:04"))
+		} else {
+			localtime.Output(time.Second, clockOutput("15:04:05"))
+		}
+	})
+	saver.Start()
+
+	quiet := dnd.New().QuietHours(22*time.Hour, 7*time.Hour+30*time.Minute).Output(func(i dnd.Info) bar.Output {
+		if !i.Paused {
+			return outputs.Pango(pango.Text("").Color(colors.Scheme("dim-icon")))
+		}
+		parts := []interface{}{pango.Text("")}
+		if i.Waiting > 0 {
+			parts = append(parts, spacer, pango.Textf("%d", i.Waiting).XSmall())
+		}
+		return outputs.Pango(parts...).Color(colors.Scheme("degraded"))
+	})
+
+	power := powermenu.New().Output(func(i powermenu.Info) bar.Output {
+		icon := map[string]string{
+			powermenu.Lock:     "",
+			powermenu.Logout:   "",
+			powermenu.Suspend:  "",
+			powermenu.Reboot:   "",
+			powermenu.Shutdown: "",
+		}[i.Action]
+		if i.Armed {
+			return outputs.Pango(pango.Text(icon), spacer, pango.Textf("%s?", i.Action)).Urgent(true)
+		}
+		return outputs.Pango(pango.Text(icon).Color(colors.Scheme("dim-icon")))
+	})
+
 	g := group.Collapsing()
 
+	pauseWhileLocked()
+	releaseOnExit(awake)
+	defer awake.Release()
 	panic(barista.Run(
+		mode,
+		urgentWs,
+		scratch,
+		title,
 		gmplay,
+		vol,
+		audioDev,
+		mic,
 		g.Add(freeMem),
 		g.Add(loadAvg),
+		g.Add(diskIO),
+		g.Add(temp),
+		g.Add(freq),
+		net,
+		wlan,
+		bt,
+		corpVPN,
 		g.Button(outputs.Text("+"), outputs.Text("-")),
 		wthr,
+		kbd,
+		bright,
+		awake,
+		profile,
 		batt,
+		quiet,
 		localtime,
+		power,
 	))
 }
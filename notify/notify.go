@@ -0,0 +1,60 @@
+// Package notify sends desktop notifications through the
+// org.freedesktop.Notifications D-Bus API on the session bus.
+package notify
+
+import (
+	"github.com/godbus/dbus"
+)
+
+// Urgency is the urgency level of a notification, which notification
+// daemons use to pick how loud or persistent a notification is.
+type Urgency byte
+
+// Urgency levels defined by the notification spec.
+const (
+	Low Urgency = iota
+	Normal
+	Critical
+)
+
+const (
+	dest = "org.freedesktop.Notifications"
+	path = dbus.ObjectPath("/org/freedesktop/Notifications")
+)
+
+// appName identifies the bar to the notification daemon.
+const appName = "mybarista"
+
+// Send shows a notification, and returns its id.
+func Send(summary, body string, urgency Urgency) (uint32, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return 0, err
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(urgency))}
+	// Critical notifications stay until dismissed.
+	timeout := int32(-1)
+	if urgency == Critical {
+		timeout = 0
+	}
+	var id uint32
+	err = conn.Object(dest, path).
+		Call(dest+".Notify", 0,
+			appName, uint32(0), "", summary, body, []string{}, hints, timeout).
+		Store(&id)
+	return id, err
+}
+
+// ServerName returns the name of the running notification daemon, e.g.
+// "dunst" or "mako".
+func ServerName() (string, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return "", err
+	}
+	var name, vendor, version, specVersion string
+	err = conn.Object(dest, path).
+		Call(dest+".GetServerInformation", 0).
+		Store(&name, &vendor, &version, &specVersion)
+	return name, err
+}
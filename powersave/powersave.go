@@ -0,0 +1,96 @@
+// Package powersave slows down polling while the laptop runs on battery.
+// Modules register their normal refresh interval, and the policy scales it
+// whenever the power source changes.
+package powersave
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aolwas/mybarista/modules/batteries"
+	"github.com/aolwas/mybarista/uevent"
+)
+
+// Policy scales refresh intervals by a constant factor on battery.
+type Policy struct {
+	factor    time.Duration
+	mu        sync.Mutex
+	onBattery bool
+	intervals []interval
+	handlers  []func(onBattery bool)
+}
+
+type interval struct {
+	normal time.Duration
+	set    func(time.Duration)
+}
+
+// New constructs a policy that multiplies intervals by factor on battery.
+func New(factor int) *Policy {
+	return &Policy{factor: time.Duration(factor), onBattery: batteries.OnBattery()}
+}
+
+// Interval registers a refresh interval. set is called immediately with
+// the interval for the current power source, and again on every change.
+func (p *Policy) Interval(normal time.Duration, set func(time.Duration)) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	iv := interval{normal, set}
+	p.intervals = append(p.intervals, iv)
+	p.apply(iv)
+	return p
+}
+
+// OnChange registers a function to call immediately with the current power
+// source, and again on every change, for settings that are not intervals.
+func (p *Policy) OnChange(fn func(onBattery bool)) *Policy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers = append(p.handlers, fn)
+	fn(p.onBattery)
+	return p
+}
+
+func (p *Policy) apply(iv interval) {
+	if p.onBattery {
+		iv.set(iv.normal * p.factor)
+	} else {
+		iv.set(iv.normal)
+	}
+}
+
+// Start watches for changes to the power source in the background.
+func (p *Policy) Start() {
+	// Without uevents (e.g. in a container), polling still works.
+	events, _ := uevent.Subscribe("power_supply")
+	// Not a barista scheduler, which would stop while the bar is paused.
+	poll := time.NewTicker(time.Minute)
+	go func() {
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+			case <-poll.C:
+			}
+			p.update(batteries.OnBattery())
+		}
+	}()
+}
+
+func (p *Policy) update(battery bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if battery == p.onBattery {
+		return
+	}
+	p.onBattery = battery
+	for _, iv := range p.intervals {
+		p.apply(iv)
+	}
+	for _, fn := range p.handlers {
+		fn(battery)
+	}
+}
@@ -0,0 +1,280 @@
+// Package pulse is a small client for PulseAudio, or PipeWire through
+// pipewire-pulse, built on top of pactl. It queries the server using pactl's
+// JSON output, and turns "pactl subscribe" into change notifications so that
+// modules can be event-driven instead of polling.
+package pulse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soumya92/barista/base"
+)
+
+// pactl is the command used to talk to the audio server.
+var pactl = "pactl"
+
+// normVolume is PA_VOLUME_NORM, the raw volume value for 100%.
+const normVolume = 65536
+
+// Device represents a sink (output) or a source (input).
+type Device struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Mute        bool   `json:"mute"`
+	Channels    map[string]struct {
+		Value int `json:"value"`
+	} `json:"volume"`
+}
+
+// Volume returns the average volume across channels, where 1 is 100%.
+func (d Device) Volume() float64 {
+	if len(d.Channels) == 0 {
+		return 0
+	}
+	total := 0
+	for _, c := range d.Channels {
+		total += c.Value
+	}
+	return float64(total) / float64(len(d.Channels)) / normVolume
+}
+
+// ServerInfo represents the server-wide state.
+type ServerInfo struct {
+	DefaultSink   string `json:"default_sink_name"`
+	DefaultSource string `json:"default_source_name"`
+}
+
+func run(args ...string) ([]byte, error) {
+	out, err := exec.Command(pactl, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("pactl %s: %v", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+func query(into interface{}, args ...string) error {
+	out, err := run(append([]string{"--format=json"}, args...)...)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(out, into)
+}
+
+// Server returns the current server information.
+func Server() (info ServerInfo, err error) {
+	err = query(&info, "info")
+	return info, err
+}
+
+// Sinks returns all sinks known to the server.
+func Sinks() (sinks []Device, err error) {
+	err = query(&sinks, "list", "sinks")
+	return sinks, err
+}
+
+// DefaultSink returns the current default sink.
+func DefaultSink() (Device, error) {
+	info, err := Server()
+	if err != nil {
+		return Device{}, err
+	}
+	sinks, err := Sinks()
+	if err != nil {
+		return Device{}, err
+	}
+	for _, s := range sinks {
+		if s.Name == info.DefaultSink {
+			return s, nil
+		}
+	}
+	return Device{}, fmt.Errorf("default sink %q not found", info.DefaultSink)
+}
+
+// Sources returns all sources known to the server, excluding the monitors
+// of sinks.
+func Sources() ([]Device, error) {
+	var all []Device
+	if err := query(&all, "list", "sources"); err != nil {
+		return nil, err
+	}
+	var sources []Device
+	for _, s := range all {
+		if !strings.HasSuffix(s.Name, ".monitor") {
+			sources = append(sources, s)
+		}
+	}
+	return sources, nil
+}
+
+// DefaultSource returns the current default source.
+func DefaultSource() (Device, error) {
+	info, err := Server()
+	if err != nil {
+		return Device{}, err
+	}
+	sources, err := Sources()
+	if err != nil {
+		return Device{}, err
+	}
+	for _, s := range sources {
+		if s.Name == info.DefaultSource {
+			return s, nil
+		}
+	}
+	return Device{}, fmt.Errorf("default source %q not found", info.DefaultSource)
+}
+
+// stream represents a sink input (playback) or source output (recording).
+type stream struct {
+	Index  int `json:"index"`
+	Source int `json:"source"`
+}
+
+// Recording returns the number of streams recording from a source.
+func Recording(source Device) (int, error) {
+	var outputs []stream
+	if err := query(&outputs, "list", "source-outputs"); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, o := range outputs {
+		if o.Source == source.Index {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CycleDefaultSink makes the sink after the current default sink the new
+// default, and moves all playing streams to it.
+func CycleDefaultSink() error {
+	info, err := Server()
+	if err != nil {
+		return err
+	}
+	sinks, err := Sinks()
+	if err != nil {
+		return err
+	}
+	return cycle(sinks, info.DefaultSink, "sink", "sink-inputs", "move-sink-input")
+}
+
+// CycleDefaultSource makes the source after the current default source the
+// new default, and moves all recording streams to it.
+func CycleDefaultSource() error {
+	info, err := Server()
+	if err != nil {
+		return err
+	}
+	sources, err := Sources()
+	if err != nil {
+		return err
+	}
+	return cycle(sources, info.DefaultSource, "source", "source-outputs", "move-source-output")
+}
+
+func cycle(devices []Device, current, kind, streams, move string) error {
+	if len(devices) == 0 {
+		return fmt.Errorf("no %ss available", kind)
+	}
+	next := devices[0]
+	for i, d := range devices {
+		if d.Name == current {
+			next = devices[(i+1)%len(devices)]
+		}
+	}
+	if _, err := run("set-default-"+kind, next.Name); err != nil {
+		return err
+	}
+	var active []stream
+	if err := query(&active, "list", streams); err != nil {
+		return err
+	}
+	for _, s := range active {
+		// Streams can go away while we move them, so errors are not fatal.
+		run(move, fmt.Sprintf("%d", s.Index), next.Name)
+	}
+	return nil
+}
+
+// SetSinkVolume sets the volume of a sink, where 1 is 100%.
+func SetSinkVolume(sink string, volume float64) error {
+	_, err := run("set-sink-volume", sink, fmt.Sprintf("%d", int(volume*normVolume)))
+	return err
+}
+
+// SetSinkMute mutes or unmutes a sink.
+func SetSinkMute(sink string, mute bool) error {
+	_, err := run("set-sink-mute", sink, fmt.Sprintf("%t", mute))
+	return err
+}
+
+// SetSourceMute mutes or unmutes a source.
+func SetSourceMute(source string, mute bool) error {
+	_, err := run("set-source-mute", source, fmt.Sprintf("%t", mute))
+	return err
+}
+
+var (
+	watchOnce sync.Once
+	changes   base.Value // of int, incremented on each relevant event
+)
+
+// relevantFacilities are the "pactl subscribe" facilities that can change
+// anything shown on the bar. Stream events (sink-input) are very frequent
+// during playback and are ignored.
+var relevantFacilities = map[string]bool{
+	"server":        true,
+	"sink":          true,
+	"source":        true,
+	"source-output": true,
+	"card":          true,
+}
+
+// Changes returns a channel that is closed on the next change to the audio
+// server's devices or defaults. The first call starts a "pactl subscribe"
+// process that is shared by all callers.
+func Changes() <-chan struct{} {
+	watchOnce.Do(func() {
+		changes.Set(0)
+		go subscribe()
+	})
+	return changes.Next()
+}
+
+func notify() {
+	changes.Set(changes.Get().(int) + 1)
+}
+
+// subscribe runs "pactl subscribe" forever, restarting it if the audio
+// server goes away (e.g. when pipewire is restarted).
+func subscribe() {
+	for {
+		cmd := exec.Command(pactl, "subscribe")
+		out, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			// The server might have changed while we were not subscribed.
+			notify()
+			s := bufio.NewScanner(out)
+			for s.Scan() {
+				// Event 'change' on sink #55
+				fields := strings.Fields(s.Text())
+				if len(fields) >= 4 && relevantFacilities[fields[3]] {
+					notify()
+				}
+			}
+			cmd.Wait()
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
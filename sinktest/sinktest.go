@@ -0,0 +1,47 @@
+// Package sinktest collects the output of a module under test.
+package sinktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soumya92/barista/bar"
+)
+
+// Sink collects the segments output by a module.
+type Sink struct {
+	t       *testing.T
+	outputs chan bar.Segments
+}
+
+// Stream starts the module, and returns a sink collecting its output.
+func Stream(t *testing.T, m bar.Module) *Sink {
+	s := &Sink{t: t, outputs: make(chan bar.Segments, 10)}
+	go m.Stream(bar.Sink(func(segments bar.Segments) { s.outputs <- segments }))
+	return s
+}
+
+// Next returns the next output, failing the test if there is none within
+// a second.
+func (s *Sink) Next() bar.Segments {
+	s.t.Helper()
+	select {
+	case segments := <-s.outputs:
+		return segments
+	case <-time.After(time.Second):
+		s.t.Fatal("no output")
+		return nil
+	}
+}
+
+// NextText returns the text of the first segment of the next output, or ""
+// if the module is hidden.
+func (s *Sink) NextText() string {
+	s.t.Helper()
+	segments := s.Next()
+	if len(segments) == 0 {
+		return ""
+	}
+	text, _ := segments[0].Content()
+	return text
+}
@@ -0,0 +1,85 @@
+// Package uevent listens for kernel uevents on a netlink socket, so that
+// modules can react to hardware changes (e.g. plugging in the charger)
+// without polling sysfs.
+package uevent
+
+import (
+	"bytes"
+	"syscall"
+)
+
+// kernelGroup is the netlink multicast group of events sent by the kernel,
+// as opposed to those re-broadcast by udev.
+const kernelGroup = 1
+
+// Event represents a single uevent.
+type Event struct {
+	// Action is e.g. "add", "remove" or "change".
+	Action string
+	// DevPath is the sysfs path of the device, relative to /sys.
+	DevPath string
+	// Subsystem is e.g. "power_supply" or "backlight".
+	Subsystem string
+	// Env holds all the key=value pairs of the event.
+	Env map[string]string
+}
+
+// Subscribe returns a channel of events for the given subsystem. The socket
+// stays open for the lifetime of the process.
+func Subscribe(subsystem string) (<-chan Event, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, err
+	}
+	addr := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: kernelGroup}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	ch := make(chan Event, 10)
+	go read(fd, subsystem, ch)
+	return ch, nil
+}
+
+func read(fd int, subsystem string, ch chan<- Event) {
+	defer syscall.Close(fd)
+	defer close(ch)
+	buf := make([]byte, 16*1024)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR || err == syscall.ENOBUFS {
+			// Interrupted, or events were dropped because we were too
+			// slow, neither is fatal.
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if e, ok := parse(buf[:n]); ok && e.Subsystem == subsystem {
+			ch <- e
+		}
+	}
+}
+
+// parse parses a kernel uevent, which is a header followed by key=value
+// pairs, all NUL separated:
+//
+//	change@/devices/.../power_supply/AC\x00ACTION=change\x00DEVPATH=...\x00
+func parse(msg []byte) (Event, bool) {
+	fields := bytes.Split(msg, []byte{0})
+	if len(fields) < 2 || !bytes.Contains(fields[0], []byte("@")) {
+		return Event{}, false
+	}
+	e := Event{Env: map[string]string{}}
+	for _, f := range fields[1:] {
+		kv := bytes.SplitN(f, []byte("="), 2)
+		if len(kv) == 2 {
+			e.Env[string(kv[0])] = string(kv[1])
+		}
+	}
+	e.Action = e.Env["ACTION"]
+	e.DevPath = e.Env["DEVPATH"]
+	e.Subsystem = e.Env["SUBSYSTEM"]
+	return e, true
+}